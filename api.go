@@ -0,0 +1,134 @@
+package tron
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Default and maximum number of items returned by the JSON API, which clients choose with the limit query parameter.
+const (
+	DefaultAPILimit = 50
+	MaxAPILimit     = 500
+)
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		glog.Errorf("writing response: %v", err)
+	}
+}
+
+func apiLimit(r *http.Request) (int, bool) {
+	s := r.URL.Query().Get("limit")
+	if s == "" {
+		return DefaultAPILimit, true
+	}
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit < 1 || limit > MaxAPILimit {
+		return 0, false
+	}
+	return limit, true
+}
+
+func writeMatches(w http.ResponseWriter, r *http.Request, id string) {
+	if Matches == nil {
+		http.Error(w, "match history is disabled", http.StatusNotFound)
+		return
+	}
+	limit, ok := apiLimit(r)
+	if !ok {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+	matches, err := Matches.Matches(id, limit)
+	if err != nil {
+		glog.Errorf("%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, matches)
+}
+
+// apiMatches serves the most recent matches.
+func apiMatches(w http.ResponseWriter, r *http.Request) {
+	writeMatches(w, r, "")
+}
+
+// apiPlayer serves /api/players/{id}/matches, the most recent matches of a player.
+func apiPlayer(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/players/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "matches" {
+		http.NotFound(w, r)
+		return
+	}
+	writeMatches(w, r, parts[0])
+}
+
+// apiLeaderboard serves the best rated players.
+func apiLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if Ratings == nil {
+		http.Error(w, "ratings are disabled", http.StatusNotFound)
+		return
+	}
+	limit, ok := apiLimit(r)
+	if !ok {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, Ratings.Top(limit))
+}
+
+// apiRooms serves the rooms of the lobby.
+func apiRooms(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, hall.Rooms())
+}
+
+// apiRoomsFeed streams the rooms of the lobby as server-sent events, sending a rooms event with the list of rooms whenever they change.
+func apiRoomsFeed(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	changes := lobbyChanges.Subscribe()
+	defer lobbyChanges.Unsubscribe(changes)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	tick := time.NewTicker(30 * time.Second)
+	defer tick.Stop()
+	var last []byte
+	for {
+		b, err := json.Marshal(hall.Rooms())
+		if err != nil {
+			glog.Errorf("%v", err)
+			return
+		}
+		if !bytes.Equal(b, last) {
+			if _, err := fmt.Fprintf(w, "event: rooms\ndata: %s\n\n", b); err != nil {
+				return
+			}
+			flusher.Flush()
+			last = b
+		}
+
+		select {
+		case <-changes:
+		case <-tick.C:
+			// Keep the connection alive.
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
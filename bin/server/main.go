@@ -7,20 +7,54 @@ import (
 
 	"github.com/golang/glog"
 
-	_ "github.com/gophergala/tron"
+	"github.com/gophergala/tron"
 )
 
 var (
-	port int
+	port       int
+	replayDir  string
+	matchFile  string
+	secretFile string
 )
 
 func init() {
 	flag.IntVar(&port, "port", 8080, "port to bind to")
+	flag.StringVar(&replayDir, "replay_dir", "", "directory to save replays to, replays are kept in memory if empty")
+	flag.StringVar(&matchFile, "match_file", "", "file to keep the match history in, from which ratings are computed at startup; recent matches are kept in memory if empty")
+	flag.StringVar(&secretFile, "identity_secret_file", "", "file holding the key signing identity tokens, created with a random key if missing; overrides IDENTITY_SECRET")
+	flag.Var(tron.ProgramFlag{}, "bot", "bot program to run as a subprocess, as name=command, which rooms can seat by name; may be repeated")
 }
 
 func main() {
 	flag.Parse()
 
+	if secretFile != "" {
+		if err := tron.LoadIdentitySecret(secretFile); err != nil {
+			glog.Fatalf("%v", err)
+		}
+	}
+	if tron.EphemeralIdentitySecret() {
+		glog.Warningf("identity tokens are signed with a random key, players will get new IDs when the server restarts; set -identity_secret_file or IDENTITY_SECRET to keep them")
+	}
+
+	if replayDir != "" {
+		store, err := tron.NewDirReplayStore(replayDir)
+		if err != nil {
+			glog.Fatalf("%v", err)
+		}
+		tron.Replays = store
+	}
+	if matchFile != "" {
+		store, err := tron.NewFileMatchStore(matchFile)
+		if err != nil {
+			glog.Fatalf("%v", err)
+		}
+		tron.Matches = store
+		if err := tron.Ratings.Rebuild(store); err != nil {
+			glog.Fatalf("%v", err)
+		}
+	}
+
 	err := http.ListenAndServe(fmt.Sprintf(":%d", port), nil)
 	if err != nil {
 		glog.Fatalf("%v", err)
@@ -0,0 +1,232 @@
+// Command tronsim plays games between bots with no server, and prints how each bot fared.
+//
+// Usage:
+//
+//	tronsim -n 1000 -bots floodfill,wallhugger,random
+//	tronsim -bot mine="python3 mybot.py" -bots mine,floodfill -replay_dir replays
+//	tronsim -tournament swiss -size 4 -rounds 8 -n 10 -bots floodfill,wallhugger,random,mine
+//
+// Seats rotate from one game to the next, so that no bot keeps the same spawn position.
+//
+// A tournament plays tables of -size bots, -n games each, and rates the bots from the results.
+// In a round robin tournament every combination of bots plays a table, while in a swiss tournament each round seats bots with similar scores together.
+// When the bots of a swiss tournament do not fill the tables, the lowest placed bots take turns sitting out a round.
+// Bots are ranked on their points per game, a bot scoring the share of its opponents it placed above in each game.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/gophergala/tron"
+)
+
+var (
+	games      int
+	bots       string
+	rules      string
+	seed       int64
+	parallel   int
+	replayDir  string
+	tournament string
+	size       int
+	rounds     int
+)
+
+func init() {
+	flag.IntVar(&games, "n", 100, "number of games to play, or in a tournament the number of games of each table")
+	flag.StringVar(&bots, "bots", "floodfill,random", "comma separated bots playing each game, from 2 to 6, or the entries of a tournament")
+	flag.StringVar(&rules, "rules", "classic", "ruleset of the games")
+	flag.Int64Var(&seed, "seed", 0, "seed of the first game, the current time if zero")
+	flag.IntVar(&parallel, "parallel", runtime.NumCPU(), "number of games played at once")
+	flag.StringVar(&replayDir, "replay_dir", "", "directory to save the replays of the games to, none are saved if empty")
+	flag.Var(tron.ProgramFlag{}, "bot", "bot program to run as a subprocess, as name=command; may be repeated")
+	flag.StringVar(&tournament, "tournament", "", "play a tournament between the bots instead, either roundrobin or swiss")
+	flag.IntVar(&size, "size", 2, "number of players of each game of a tournament, 2 or 4")
+	flag.IntVar(&rounds, "rounds", 5, "number of rounds of a swiss tournament")
+}
+
+var (
+	ruleset tron.Ruleset
+	store   tron.ReplayStore
+)
+
+// match is a game to play between bots, seated from the color at index Rotation onwards so that spawn positions change between games.
+type match struct {
+	Names    []string
+	Seed     int64
+	Rotation int
+}
+
+// outcome is how a match went.
+type outcome struct {
+	Seats  map[tron.Color]string
+	Ticks  int
+	Result tron.GameResult
+	Err    error
+}
+
+func play(m match) outcome {
+	o := outcome{Seats: make(map[tron.Color]string)}
+	players := make(map[tron.Color]tron.Bot)
+	for j, name := range m.Names {
+		color := tron.Colors[(m.Rotation+j)%len(m.Names)]
+		o.Seats[color] = name
+		players[color] = tron.Bots[name](rand.New(rand.NewSource(m.Seed + int64(j))))
+	}
+	arena, rec, err := tron.Simulate(ruleset, players, m.Seed)
+	for _, b := range players {
+		if c, ok := b.(io.Closer); ok {
+			c.Close()
+		}
+	}
+	if err != nil {
+		o.Err = err
+		return o
+	}
+	if store != nil {
+		if err := store.Save(rec); err != nil {
+			glog.Errorf("saving replay %s: %v", rec.ID, err)
+		}
+	}
+	o.Ticks = arena.Tick
+	o.Result = tron.NewGameResult(arena.Colors(), arena)
+	return o
+}
+
+// playAll plays matches, parallel games at a time, and returns their outcomes in the order of matches.
+func playAll(matches []match) []outcome {
+	outcomes := make([]outcome, len(matches))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				outcomes[i] = play(matches[i])
+			}
+		}()
+	}
+	for i := range matches {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return outcomes
+}
+
+// stats are the results of a bot over all games.
+type stats struct {
+	games  int
+	wins   int
+	draws  int
+	causes map[string]int
+}
+
+// causes are the causes of elimination, as returned by tron.Placement.Cause.
+var causes = []string{"wall", "self", "other", "head-on"}
+
+func main() {
+	flag.Parse()
+
+	names := strings.Split(bots, ",")
+	for _, name := range names {
+		if _, ok := tron.Bots[name]; !ok {
+			glog.Fatalf("unknown bot %q", name)
+		}
+	}
+	var ok bool
+	if ruleset, ok = tron.Rulesets[rules]; !ok {
+		glog.Fatalf("unknown ruleset %q", rules)
+	}
+	if replayDir != "" {
+		var err error
+		if store, err = tron.NewDirReplayStore(replayDir); err != nil {
+			glog.Fatalf("%v", err)
+		}
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	if tournament != "" {
+		runTournament(names)
+		return
+	}
+	if len(names) < 2 || len(names) > len(tron.Colors) {
+		glog.Fatalf("between 2 and %d bots are needed, got %d", len(tron.Colors), len(names))
+	}
+
+	matches := make([]match, games)
+	for i := range matches {
+		matches[i] = match{Names: names, Seed: seed + int64(i), Rotation: i}
+	}
+	results := make(map[string]*stats)
+	for _, name := range names {
+		results[name] = &stats{causes: make(map[string]int)}
+	}
+	totalTicks, failed := 0, 0
+	for i, o := range playAll(matches) {
+		if o.Err != nil {
+			glog.Errorf("game %d: %v", i, o.Err)
+			failed += 1
+			continue
+		}
+		totalTicks += o.Ticks
+		for _, p := range o.Result.Placements {
+			s := results[o.Seats[p.Color]]
+			s.games += 1
+			switch {
+			case p.Place == 1 && o.Result.Draw:
+				s.draws += 1
+			case p.Place == 1:
+				s.wins += 1
+			default:
+				s.causes[p.Cause()] += 1
+			}
+		}
+	}
+
+	played := games - failed
+	fmt.Printf("%d games from seed %d, %d failed", played, seed, failed)
+	if played > 0 {
+		fmt.Printf(", %.1f ticks on average", float64(totalTicks)/float64(played))
+	}
+	fmt.Println()
+
+	sorted := make([]string, 0, len(results))
+	for name, _ := range results {
+		sorted = append(sorted, name)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return results[sorted[i]].wins*results[sorted[j]].games > results[sorted[j]].wins*results[sorted[i]].games
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "bot\tgames\twins\tdraws\twin rate\t%s\t\n", strings.Join(causes, "\t"))
+	for _, name := range sorted {
+		s := results[name]
+		rate := 0.0
+		if s.games > 0 {
+			rate = 100 * float64(s.wins) / float64(s.games)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.1f%%\t", name, s.games, s.wins, s.draws, rate)
+		for _, c := range causes {
+			fmt.Fprintf(w, "%d\t", s.causes[c])
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+}
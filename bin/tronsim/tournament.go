@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/golang/glog"
+
+	"github.com/gophergala/tron"
+)
+
+// entry is a bot in a tournament.
+type entry struct {
+	name   string
+	games  int
+	wins   int
+	draws  int
+	points float64
+	rating float64
+	// byes is the number of rounds the bot sat out.
+	byes int
+	// met is the number of tables the bot shared with each other bot.
+	met map[string]int
+}
+
+// combinations returns every combination of k names.
+func combinations(names []string, k int) [][]string {
+	if k == 0 {
+		return [][]string{nil}
+	}
+	var combs [][]string
+	for i := 0; i+k <= len(names); i++ {
+		for _, c := range combinations(names[i+1:], k-1) {
+			combs = append(combs, append([]string{names[i]}, c...))
+		}
+	}
+	return combs
+}
+
+// swissTables seats the entries, sorted by standing, at tables of size bots, and returns the bots sitting out the round.
+// When the bots do not fill the tables, the lowest placed bots among those which sat out the fewest rounds sit this one out.
+// At tables of two, a bot is seated with the best placed bot it met the least, while at larger tables bots are seated in order.
+func swissTables(sorted []*entry) ([][]string, []*entry) {
+	bottom := make([]*entry, len(sorted))
+	for i, e := range sorted {
+		bottom[len(sorted)-1-i] = e
+	}
+	sort.SliceStable(bottom, func(i, j int) bool { return bottom[i].byes < bottom[j].byes })
+	byes := bottom[:len(sorted)%size]
+
+	var tables [][]string
+	seated := make(map[string]bool)
+	for _, e := range byes {
+		seated[e.name] = true
+	}
+	for i, e := range sorted {
+		if seated[e.name] {
+			continue
+		}
+		table := []string{e.name}
+		seated[e.name] = true
+		if size == 2 {
+			var best *entry
+			for _, o := range sorted[i+1:] {
+				if !seated[o.name] && (best == nil || e.met[o.name] < e.met[best.name]) {
+					best = o
+				}
+			}
+			table = append(table, best.name)
+			seated[best.name] = true
+		} else {
+			for _, o := range sorted[i+1:] {
+				if len(table) == size {
+					break
+				}
+				if !seated[o.name] {
+					table = append(table, o.name)
+					seated[o.name] = true
+				}
+			}
+		}
+		tables = append(tables, table)
+	}
+	return tables, byes
+}
+
+// perGame returns the points of e per game played, by which bots are ranked as they may not play as many games.
+func (e *entry) perGame() float64 {
+	if e.games == 0 {
+		return 0
+	}
+	return e.points / float64(e.games)
+}
+
+// standings returns the entries sorted by points per game, then rating.
+func standings(entries map[string]*entry) []*entry {
+	sorted := make([]*entry, 0, len(entries))
+	for _, e := range entries {
+		sorted = append(sorted, e)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.perGame() != b.perGame() {
+			return a.perGame() > b.perGame()
+		}
+		if a.rating != b.rating {
+			return a.rating > b.rating
+		}
+		return a.name < b.name
+	})
+	return sorted
+}
+
+// record updates the entries and their ratings with the outcome of a game.
+// A bot scores the share of its opponents it placed above, ties counting for half.
+func record(entries map[string]*entry, o outcome) {
+	places := make(map[string]int)
+	for _, p := range o.Result.Placements {
+		places[o.Seats[p.Color]] = p.Place
+	}
+	ratings := make(map[string]float64)
+	for name, _ := range places {
+		ratings[name] = entries[name].rating
+	}
+	for name, r := range tron.UpdateRatings(ratings, places) {
+		e := entries[name]
+		e.rating = r
+		e.games += 1
+		score := 0.0
+		for other, place := range places {
+			if other == name {
+				continue
+			}
+			e.met[other] += 1
+			if places[name] < place {
+				score += 1
+			} else if places[name] == place {
+				score += 0.5
+			}
+		}
+		e.points += score / float64(len(places)-1)
+		if places[name] == 1 {
+			if o.Result.Draw {
+				e.draws += 1
+			} else {
+				e.wins += 1
+			}
+		}
+	}
+}
+
+func runTournament(names []string) {
+	if size != 2 && size != 4 {
+		glog.Fatalf("tournament games are between 2 or 4 bots, not %d", size)
+	}
+	if len(names) < size {
+		glog.Fatalf("a tournament of %d player games needs at least %d bots, got %d", size, size, len(names))
+	}
+	entries := make(map[string]*entry)
+	for _, name := range names {
+		if _, ok := entries[name]; ok {
+			glog.Fatalf("bot %q entered twice", name)
+		}
+		entries[name] = &entry{name: name, rating: tron.InitialRating, met: make(map[string]int)}
+	}
+
+	var n int
+	switch tournament {
+	case "roundrobin":
+		n = 1
+	case "swiss":
+		n = rounds
+	default:
+		glog.Fatalf("unknown tournament %q, tournaments are roundrobin and swiss", tournament)
+	}
+
+	gameSeed, failed := seed, 0
+	for round := 0; round < n; round++ {
+		var tables [][]string
+		if tournament == "roundrobin" {
+			tables = combinations(names, size)
+		} else {
+			var byes []*entry
+			tables, byes = swissTables(standings(entries))
+			for _, e := range byes {
+				e.byes += 1
+			}
+		}
+		var matches []match
+		for _, table := range tables {
+			for i := 0; i < games; i++ {
+				matches = append(matches, match{Names: table, Seed: gameSeed, Rotation: i})
+				gameSeed += 1
+			}
+		}
+		for i, o := range playAll(matches) {
+			if o.Err != nil {
+				glog.Errorf("round %d, game %d: %v", round+1, i, o.Err)
+				failed += 1
+				continue
+			}
+			record(entries, o)
+		}
+	}
+
+	fmt.Printf("%s tournament of %d player games from seed %d, %d games failed\n", tournament, size, seed, failed)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "\tbot\tgames\tbyes\twins\tdraws\tpoints\tper game\trating\t\n")
+	for i, e := range standings(entries) {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%d\t%d\t%.1f\t%.3f\t%.0f\t\n", i+1, e.name, e.games, e.byes, e.wins, e.draws, e.points, e.perGame(), e.rating)
+	}
+	w.Flush()
+}
@@ -0,0 +1,228 @@
+package tron
+
+import (
+	"io"
+	"math/rand"
+)
+
+// Bot decides the moves of a computer player.
+type Bot interface {
+	// Move returns the direction of the snake of color for the next timestep, or an empty direction to keep going.
+	Move(s Snapshot, color Color) Direction
+}
+
+// Bots are the built-in bots by name, each created with its own source of randomness.
+var Bots = map[string]func(rnd *rand.Rand) Bot{
+	"random":     func(rnd *rand.Rand) Bot { return &RandomBot{Rand: rnd} },
+	"wallhugger": func(rnd *rand.Rand) Bot { return WallHuggerBot{} },
+	"floodfill":  func(rnd *rand.Rand) Bot { return FloodFillBot{} },
+}
+
+// Grid is the set of cells of an arena which are occupied by a wall or a trail.
+type Grid struct {
+	Size  Point
+	cells []bool
+}
+
+func NewGrid(s Snapshot) *Grid {
+	g := Grid{Size: s.Size, cells: make([]bool, s.Size.X*s.Size.Y)}
+	for _, snake := range s.Snakes {
+		for i := 1; i < len(snake); i++ {
+			from, to := snake[i-1], snake[i]
+			for p := from; ; p = stepTowards(p, to) {
+				g.Set(p)
+				if p == to {
+					break
+				}
+			}
+		}
+	}
+	return &g
+}
+
+func stepTowards(p, to Point) Point {
+	switch {
+	case p.X < to.X:
+		p.X += 1
+	case p.X > to.X:
+		p.X -= 1
+	case p.Y < to.Y:
+		p.Y += 1
+	case p.Y > to.Y:
+		p.Y -= 1
+	}
+	return p
+}
+
+func (g *Grid) inside(p Point) bool {
+	return p.X > 0 && p.X < g.Size.X && p.Y > 0 && p.Y < g.Size.Y
+}
+
+// Free reports whether p is inside the arena and not occupied.
+func (g *Grid) Free(p Point) bool {
+	return g.inside(p) && !g.cells[p.Y*g.Size.X+p.X]
+}
+
+func (g *Grid) Set(p Point) {
+	if g.inside(p) {
+		g.cells[p.Y*g.Size.X+p.X] = true
+	}
+}
+
+// Reachable returns the number of free cells reachable from p, up to max.
+func (g *Grid) Reachable(p Point, max int) int {
+	if !g.Free(p) {
+		return 0
+	}
+	seen := make([]bool, len(g.cells))
+	seen[p.Y*g.Size.X+p.X] = true
+	queue := []Point{p}
+	count := 1
+	for len(queue) > 0 && count < max {
+		q := queue[0]
+		queue = queue[1:]
+		for _, d := range []Direction{DirectionUp, DirectionDown, DirectionLeft, DirectionRight} {
+			n := next(q, d)
+			if !g.Free(n) || seen[n.Y*g.Size.X+n.X] {
+				continue
+			}
+			seen[n.Y*g.Size.X+n.X] = true
+			queue = append(queue, n)
+			count += 1
+		}
+	}
+	return count
+}
+
+func next(p Point, d Direction) Point {
+	switch d {
+	case DirectionUp:
+		return Point{X: p.X, Y: p.Y + 1}
+	case DirectionDown:
+		return Point{X: p.X, Y: p.Y - 1}
+	case DirectionLeft:
+		return Point{X: p.X - 1, Y: p.Y}
+	case DirectionRight:
+		return Point{X: p.X + 1, Y: p.Y}
+	}
+	return p
+}
+
+// safeMoves returns the directions the snake of color can take without crashing on the next timestep, going straight first.
+func safeMoves(s Snapshot, g *Grid, color Color) (Point, []Direction) {
+	snake := s.Snakes[color]
+	if len(snake) < 2 {
+		return Point{}, nil
+	}
+	head := snake[len(snake)-1]
+	dirt := computeDirection(snake)
+	candidates := []Direction{dirt}
+	for _, d := range []Direction{DirectionUp, DirectionDown, DirectionLeft, DirectionRight} {
+		if d != dirt && !oppositeDirections(d, dirt) {
+			candidates = append(candidates, d)
+		}
+	}
+	moves := make([]Direction, 0, len(candidates))
+	for _, d := range candidates {
+		if g.Free(next(head, d)) {
+			moves = append(moves, d)
+		}
+	}
+	return head, moves
+}
+
+// RandomBot goes straight until it has to turn, or sometimes at random, always avoiding an immediate crash.
+type RandomBot struct {
+	Rand *rand.Rand
+}
+
+func (b *RandomBot) Move(s Snapshot, color Color) Direction {
+	_, moves := safeMoves(s, NewGrid(s), color)
+	if len(moves) == 0 {
+		return ""
+	}
+	straight := moves[0] == computeDirection(s.Snakes[color])
+	if straight && b.Rand.Intn(10) != 0 {
+		return moves[0]
+	}
+	return moves[b.Rand.Intn(len(moves))]
+}
+
+// WallHuggerBot follows walls and trails, preferring the move which keeps the most occupied cells around it.
+// Moves into a space much smaller than the largest one available are avoided, so that it does not wrap itself up.
+type WallHuggerBot struct{}
+
+func (WallHuggerBot) Move(s Snapshot, color Color) Direction {
+	g := NewGrid(s)
+	head, moves := safeMoves(s, g, color)
+	space := make([]int, len(moves))
+	maxSpace := 0
+	for i, d := range moves {
+		space[i] = g.Reachable(next(head, d), floodFillMax)
+		if space[i] > maxSpace {
+			maxSpace = space[i]
+		}
+	}
+	best, bestScore := Direction(""), -1
+	for i, d := range moves {
+		if space[i] < maxSpace/2 {
+			continue
+		}
+		p := next(head, d)
+		score := 0
+		for _, n := range []Direction{DirectionUp, DirectionDown, DirectionLeft, DirectionRight} {
+			if q := next(p, n); q != head && !g.Free(q) {
+				score += 1
+			}
+		}
+		if score > bestScore {
+			best, bestScore = d, score
+		}
+	}
+	return best
+}
+
+// FloodFillBot moves towards the largest free space.
+type FloodFillBot struct{}
+
+// floodFillMax bounds the search of FloodFillBot, past which spaces are considered equally large.
+const floodFillMax = 4000
+
+func (FloodFillBot) Move(s Snapshot, color Color) Direction {
+	g := NewGrid(s)
+	head, moves := safeMoves(s, g, color)
+	best, bestScore := Direction(""), -1
+	for _, d := range moves {
+		score := g.Reachable(next(head, d), floodFillMax)
+		if score > bestScore {
+			best, bestScore = d, score
+		}
+	}
+	return best
+}
+
+// playBot plays the moves of bot for the snake of color in game, until the game ends.
+// Bots which implement io.Closer are closed once the game ends.
+func playBot(game *Game, color Color, bot Bot, me *Player) {
+	if c, ok := bot.(io.Closer); ok {
+		defer c.Close()
+	}
+	for {
+		select {
+		case s := <-me.Arena:
+			snake := s.Snakes[color]
+			if len(snake) < 2 {
+				break
+			}
+			if d := bot.Move(s, color); d != "" && d != computeDirection(snake) {
+				select {
+				case game.Move <- MoveCmd{Color: color, Direction: d}:
+				default:
+				}
+			}
+		case <-me.Countdown:
+		case <-me.GameEnd:
+			return
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package tron
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/gophergala/tron/protocol"
+)
+
+// Cells returns the cells occupied by each snake of s, from its tail to its head.
+func (s Snapshot) Cells() map[Color][]Point {
+	cells := make(map[Color][]Point)
+	for color, snake := range s.Snakes {
+		if len(snake) == 0 {
+			continue
+		}
+		c := []Point{snake[0]}
+		for i := 1; i < len(snake); i++ {
+			for p := snake[i-1]; p != snake[i]; {
+				p = stepTowards(p, snake[i])
+				c = append(c, p)
+			}
+		}
+		cells[color] = c
+	}
+	return cells
+}
+
+func newObservation(snapshot Snapshot, color Color) protocol.Observation {
+	cells := make(map[protocol.Color][]protocol.Point)
+	for color, c := range snapshot.Cells() {
+		points := make([]protocol.Point, len(c))
+		for i, p := range c {
+			points[i] = protocol.Point{X: p.X, Y: p.Y}
+		}
+		cells[protocol.Color(color)] = points
+	}
+	losers := make([]protocol.Loser, 0, len(snapshot.Losers))
+	for _, l := range snapshot.Losers {
+		losers = append(losers, protocol.Loser{Color: protocol.Color(l.Color), CollideWith: protocol.Color(l.CollideWith), Tick: l.Tick, HeadOn: l.HeadOn})
+	}
+	timeLeft := snapshot.Deadline.Sub(time.Now())
+	if timeLeft < 0 {
+		timeLeft = 0
+	}
+	return protocol.Observation{
+		Type:     protocol.TypeObservation,
+		Tick:     snapshot.Tick,
+		You:      protocol.Color(color),
+		Deadline: snapshot.Deadline.UnixNano() / int64(time.Millisecond),
+		TimeLeft: int(timeLeft / time.Millisecond),
+		Size:     protocol.Point{X: snapshot.Size.X, Y: snapshot.Size.Y},
+		Cells:    cells,
+		Losers:   losers,
+	}
+}
+
+// botTurn is the tick an external bot is expected to move for, which is the tick of the last observation sent to it.
+type botTurn struct {
+	sync.Mutex
+	game     *Game
+	color    Color
+	tick     int
+	deadline time.Time
+	moved    bool
+}
+
+// observe makes the tick of snapshot the current one, and returns the color of the bot.
+func (t *botTurn) observe(snapshot Snapshot) Color {
+	t.Lock()
+	defer t.Unlock()
+	t.tick = snapshot.Tick
+	t.deadline = snapshot.Deadline
+	t.moved = false
+	return t.color
+}
+
+func (t *botTurn) join(game *Game, color Color) {
+	t.Lock()
+	defer t.Unlock()
+	t.game, t.color = game, color
+	t.tick, t.deadline, t.moved = 0, time.Time{}, false
+}
+
+// move forwards m to the game if it is the first move for the current tick and arrives before the deadline.
+func (t *botTurn) move(m *protocol.BotMove) error {
+	t.Lock()
+	defer t.Unlock()
+	if m.Tick != t.tick || t.deadline.IsZero() || time.Now().After(t.deadline) {
+		return protocol.NewError(protocol.ErrLateMove, fmt.Sprintf("move for tick %d is late, the current tick is %d", m.Tick, t.tick))
+	}
+	if t.moved {
+		return protocol.NewError(protocol.ErrUnexpectedMessage, fmt.Sprintf("already moved for tick %d", m.Tick))
+	}
+	t.moved = true
+	select {
+	case t.game.Move <- MoveCmd{Color: t.color, Direction: Direction(m.Direction)}:
+	default:
+	}
+	return nil
+}
+
+// BotJoin seats an external bot, which plays in a room like any other player.
+// Instead of map frames, the bot receives an Observation on every tick and replies with a BotMove for that tick.
+// Bots are never watchers: the connection is closed if the room is full.
+// A bot asking for a quick match is queued with the players of /Join.
+func BotJoin(ws *websocket.Conn) {
+	join, ok := receiveJoin(ws)
+	if !ok {
+		return
+	}
+	identity, token := identify(join)
+	me := &Player{
+		Identity:  identity,
+		Arena:     make(chan Snapshot, 32),
+		GameEnd:   make(chan GameResult, 4),
+		Countdown: make(chan int, 4),
+	}
+	var room *Room
+	if join.QuickMatch {
+		if room, ok = quickMatch(ws, me); !ok {
+			return
+		}
+	} else {
+		var err error
+		room, err = hall.EnterRoom(join.Room, roomConfig(join), me)
+		if err == ErrRoomFull {
+			websocket.JSON.Send(ws, protocol.NewError(protocol.ErrRoomFull, err.Error()))
+			return
+		}
+		if err != nil {
+			websocket.JSON.Send(ws, protocol.NewError(protocol.ErrInvalidConfig, err.Error()))
+			return
+		}
+	}
+	defer hall.LeaveRoom(room.Name, me)
+
+	turn := &botTurn{}
+	game, color := room.Ready(me)
+	turn.join(game, color)
+	if err := websocket.JSON.Send(ws, protocol.NewConnected(protocol.Color(color), newIdentity(identity), token)); err != nil {
+		return
+	}
+
+	readStopped := make(chan struct{})
+	go func() {
+		defer close(readStopped)
+		for {
+			msg, err := receive(ws)
+			if err != nil {
+				return
+			}
+			switch m := msg.(type) {
+			case *protocol.Leave:
+				return
+			case *protocol.Ready:
+				game, color := room.Ready(me)
+				turn.join(game, color)
+				if err := websocket.JSON.Send(ws, protocol.NewConnected(protocol.Color(color), newIdentity(identity), token)); err != nil {
+					return
+				}
+			case *protocol.BotMove:
+				if err := turn.move(m); err != nil {
+					if err := websocket.JSON.Send(ws, err); err != nil {
+						return
+					}
+				}
+			default:
+				if err := websocket.JSON.Send(ws, protocol.NewError(protocol.ErrUnexpectedMessage, fmt.Sprintf("unexpected message %T", m))); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	sendLoop(ws, me, func(snapshot Snapshot) interface{} {
+		color := turn.observe(snapshot)
+		b, _ := json.Marshal(newObservation(snapshot, color))
+		return string(b)
+	}, readStopped)
+}
@@ -0,0 +1,156 @@
+// Package client is a client for the game protocol spoken on the /Join websocket, for bots, load testers and alternative clients.
+package client
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/gophergala/tron/protocol"
+)
+
+// Client is a connection to a game server.
+//
+// Messages from the server are delivered on Events as pointers to protocol messages:
+// *protocol.Hello, *protocol.Queued, *protocol.Matched, *protocol.Connected, *protocol.Countdown, *protocol.RefreshMap, *protocol.GameEnd, *protocol.Error and *protocol.Heartbeat,
+// and *protocol.Observation on the /Bot websocket.
+// Delta messages are applied by the client, which delivers the resulting state as a *protocol.RefreshMap, with the players of the last keyframe.
+type Client struct {
+	Events <-chan interface{}
+
+	ws *websocket.Conn
+
+	mu      sync.Mutex
+	err     error
+	state   map[protocol.Color][]protocol.Point
+	players map[protocol.Color]protocol.Identity
+	seq     int
+}
+
+// Dial connects to the /Join or /Bot websocket at url, for example ws://localhost:8080/Join.
+// origin is the origin of the client, for example http://localhost/.
+func Dial(url, origin string) (*Client, error) {
+	ws, err := websocket.Dial(url, "", origin)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan interface{}, 64)
+	c := Client{
+		Events: events,
+		ws:     ws,
+	}
+	go c.read(events)
+	return &c, nil
+}
+
+func (c *Client) send(typ string, body interface{}) error {
+	return websocket.JSON.Send(c.ws, struct {
+		Type string
+		Body interface{}
+	}{Type: typ, Body: body})
+}
+
+// JoinRoom joins a room, which must be the first request of a client.
+// The latest version of the protocol is requested if join.Version is zero.
+func (c *Client) JoinRoom(join protocol.Join) error {
+	if join.Version == 0 {
+		join.Version = protocol.Version
+	}
+	return c.send(protocol.TypeJoin, join)
+}
+
+// Ready asks to play the next game in the room.
+func (c *Client) Ready() error {
+	return c.send(protocol.TypeReady, protocol.Ready{})
+}
+
+// Move changes the direction of the player, which is one of protocol.DirectionUp, DirectionDown, DirectionLeft and DirectionRight.
+func (c *Client) Move(direction string) error {
+	return c.send(protocol.TypeMove, protocol.Move{Direction: direction})
+}
+
+// BotMove changes the direction of an external bot for tick, which is the tick of the last Observation.
+func (c *Client) BotMove(tick int, direction string) error {
+	return c.send(protocol.TypeBotMove, protocol.BotMove{Tick: tick, Direction: direction})
+}
+
+// Leave leaves the room, after which the server closes the connection.
+func (c *Client) Leave() error {
+	return c.send(protocol.TypeLeave, nil)
+}
+
+// RequestKeyframe asks for the next map frame to hold the full state of the arena.
+func (c *Client) RequestKeyframe() error {
+	return c.send(protocol.TypeKeyframe, nil)
+}
+
+func (c *Client) Close() error {
+	return c.ws.Close()
+}
+
+// Err returns the error which ended the connection, once Events is closed.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Client) read(events chan<- interface{}) {
+	defer close(events)
+	for {
+		var b []byte
+		if err := websocket.Message.Receive(c.ws, &b); err != nil {
+			c.mu.Lock()
+			c.err = err
+			c.mu.Unlock()
+			return
+		}
+
+		var msg interface{}
+		var err error
+		if len(b) > 0 && b[0] == '{' {
+			msg, err = protocol.Decode(b)
+		} else {
+			msg, err = protocol.DecodeFrame(b)
+		}
+		if err != nil {
+			events <- protocol.NewError(protocol.ErrBadMessage, fmt.Sprintf("invalid message from server: %v", err))
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *protocol.RefreshMap:
+			c.state = m.State
+			c.seq = m.Seq
+			if m.Players != nil {
+				c.players = m.Players
+			}
+		case *protocol.Delta:
+			if m.Seq != c.seq+1 || c.state == nil {
+				c.RequestKeyframe()
+				continue
+			}
+			msg = c.apply(m)
+		}
+		events <- msg
+	}
+}
+
+// apply applies a delta to the state of the arena, and returns the new state.
+func (c *Client) apply(d *protocol.Delta) *protocol.RefreshMap {
+	state := make(map[protocol.Color][]protocol.Point)
+	for color, points := range c.state {
+		state[color] = points
+	}
+	for color, sd := range d.Snakes {
+		points := state[color]
+		if sd.From > len(points) {
+			sd.From = len(points)
+		}
+		state[color] = append(append([]protocol.Point(nil), points[:sd.From]...), sd.Points...)
+	}
+	c.state = state
+	c.seq = d.Seq
+	return &protocol.RefreshMap{Type: protocol.TypeRefreshMap, Seq: d.Seq, Tick: d.Tick, State: state, Players: c.players}
+}
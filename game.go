@@ -1,19 +1,53 @@
 package tron
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
 	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
+
+	"github.com/gophergala/tron/protocol"
 )
 
 type Color string
 
-var Colors = []Color{"blue", "red", "green", "orange", "black", "purple"}
+// Colors are the colors handed out to players, in order.
+var Colors = make([]Color, 0, len(protocol.Colors))
 
-const ColorWall = "wall"
+func init() {
+	for _, c := range protocol.Colors {
+		Colors = append(Colors, Color(c))
+	}
+}
+
+// SortColors sorts colors in the order of Colors, unknown colors come last in lexical order.
+func SortColors(colors []Color) {
+	index := func(c Color) int {
+		for i, color := range Colors {
+			if color == c {
+				return i
+			}
+		}
+		return len(Colors)
+	}
+	sort.Slice(colors, func(i, j int) bool {
+		ci, cj := index(colors[i]), index(colors[j])
+		if ci != cj {
+			return ci < cj
+		}
+		return colors[i] < colors[j]
+	})
+}
+
+const ColorWall = Color(protocol.ColorWall)
 
 type JoinCmd struct {
 	ColorC chan Color
@@ -27,12 +61,16 @@ type LeaveCmd struct {
 type Direction string
 
 const (
-	DirectionUp    = "u"
-	DirectionDown  = "d"
-	DirectionLeft  = "l"
-	DirectionRight = "r"
+	DirectionUp    = protocol.DirectionUp
+	DirectionDown  = protocol.DirectionDown
+	DirectionLeft  = protocol.DirectionLeft
+	DirectionRight = protocol.DirectionRight
 )
 
+func validDirection(d Direction) bool {
+	return d == DirectionUp || d == DirectionDown || d == DirectionLeft || d == DirectionRight
+}
+
 type MoveCmd struct {
 	Color     Color
 	Direction Direction
@@ -46,15 +84,95 @@ type Point struct {
 type Loser struct {
 	Color       Color
 	CollideWith Color
+	Tick        int
+
+	// HeadOn is set when the loser crashed head to head into CollideWith, in which case the game is a draw between them.
+	HeadOn bool
+}
+
+// Placement is the final standing of a player in a game.
+// Players eliminated on the same tick share the same Place.
+type Placement struct {
+	Color Color
+	Place int
+
+	// Tick is the tick on which the player was eliminated, or zero if the player survived.
+	Tick        int
+	CollideWith Color
+	HeadOn      bool
+}
+
+// Cause returns why the player was eliminated: "wall", "self" for its own trail, "other" for the trail of another player, or "head-on".
+// It is empty for a survivor.
+func (p Placement) Cause() string {
+	switch {
+	case p.Tick == 0:
+		return ""
+	case p.HeadOn:
+		return "head-on"
+	case p.CollideWith == ColorWall:
+		return "wall"
+	case p.CollideWith == p.Color:
+		return "self"
+	}
+	return "other"
+}
+
+type GameResult struct {
+	// Winner is empty when the game ended in a draw.
+	Winner     Color
+	Draw       bool
+	Placements []Placement
+
+	// Replay is the ID of the recording of the game, empty if it was not recorded.
+	Replay string
+
+	Players map[Color]Identity
+}
+
+// NewGameResult computes the finishing order of the players of colors from the history of eliminations of an arena.
+// Survivors come first, followed by the losers in the reverse order of their elimination.
+func NewGameResult(colors []Color, a *Arena) GameResult {
+	placements := make([]Placement, 0, len(colors))
+	for _, color := range colors {
+		if !a.lost(color) {
+			placements = append(placements, Placement{Color: color})
+		}
+	}
+	for i := len(a.Losers) - 1; i >= 0; i-- {
+		l := a.Losers[i]
+		placements = append(placements, Placement{Color: l.Color, Tick: l.Tick, CollideWith: l.CollideWith, HeadOn: l.HeadOn})
+	}
+
+	for i := range placements {
+		if i > 0 && placements[i].Tick == placements[i-1].Tick {
+			placements[i].Place = placements[i-1].Place
+		} else {
+			placements[i].Place = i + 1
+		}
+	}
+
+	result := GameResult{Placements: placements}
+	if len(placements) > 1 && placements[1].Place == 1 {
+		result.Draw = true
+	} else if len(placements) > 0 {
+		result.Winner = placements[0].Color
+	}
+	return result
 }
 
 type Arena struct {
 	Snakes map[Color][]Point
 	Points map[Color]map[Point]struct{}
 	Losers []Loser
+	Tick   int
 
 	Size  Point
 	Ratio float64
+
+	Rules Ruleset
+	// Rand is the source of anything random in the arena, so that a game is fully determined by its seed and inputs.
+	Rand *rand.Rand
 }
 
 const (
@@ -63,14 +181,38 @@ const (
 	DefaultSizeRatio = 4
 )
 
-func NewArena(snakes map[Color][]Point, ratio float64) *Arena {
+const (
+	DefaultTickRate = 20
+	MinTickRate     = 10
+	MaxTickRate     = 60
+
+	// MaxCatchUpTicks is how many timesteps a late game loop runs back to back before giving up on catching up.
+	MaxCatchUpTicks = 5
+)
+
+// TickPeriod returns the duration of a timestep at rate timesteps per second.
+func TickPeriod(rate int) time.Duration {
+	return time.Second / time.Duration(rate)
+}
+
+// MaxQueuedMoves is the number of moves a player can queue, one of which is applied per timestep.
+const MaxQueuedMoves = 4
+
+// ArenaSize returns the size in cells of an arena with the given ratio.
+func ArenaSize(ratio float64) Point {
+	return Point{X: int(DefaultSizeX / ratio), Y: int(DefaultSizeY / ratio)}
+}
+
+func NewArena(rules Ruleset, snakes map[Color][]Point, ratio float64, rnd *rand.Rand) *Arena {
 	a := Arena{
 		Snakes: snakes,
 		Points: make(map[Color]map[Point]struct{}),
 		Losers: make([]Loser, 0),
 		Ratio:  ratio,
+		Rules:  rules,
+		Rand:   rnd,
 	}
-	a.Size = Point{X: int(DefaultSizeX / a.Ratio), Y: int(DefaultSizeY / a.Ratio)}
+	a.Size = ArenaSize(a.Ratio)
 	for color, s := range snakes {
 		a.Points[color] = make(map[Point]struct{})
 		for _, point := range s {
@@ -82,7 +224,10 @@ func NewArena(snakes map[Color][]Point, ratio float64) *Arena {
 
 // ChangeInitDirt sets the initial direction by altering the second point of the color's snake.
 func (a *Arena) ChangeInitDirt(cmd MoveCmd) bool {
-	snake := a.Snakes[cmd.Color]
+	snake, ok := a.Snakes[cmd.Color]
+	if !ok || !validDirection(cmd.Direction) {
+		return false
+	}
 	prevDirt := computeDirection(snake)
 	changed := false
 	if cmd.Direction != prevDirt {
@@ -132,92 +277,190 @@ func oppositeDirections(a, b Direction) bool {
 	return false
 }
 
-// Update updates the state of an arena for a timestep.
-func (a *Arena) Update(acts map[Color]Direction) {
-	for color, snake := range a.Snakes {
-		lost := false
-		for _, l := range a.Losers {
-			if l.Color == color {
-				lost = true
-				break
-			}
-		}
-		if lost {
-			continue
-		}
+// Colors returns the colors of the snakes of the arena in the order of Colors.
+func (a *Arena) Colors() []Color {
+	colors := make([]Color, 0, len(a.Snakes))
+	for color, _ := range a.Snakes {
+		colors = append(colors, color)
+	}
+	SortColors(colors)
+	return colors
+}
 
-		prevDirt := computeDirection(snake)
-		dirt := prevDirt
-		if act, ok := acts[color]; ok && !oppositeDirections(act, prevDirt) {
-			dirt = act
+// Hash returns a hash of the state of the arena, which is identical for two games with the same seed and inputs at the same tick.
+func (a *Arena) Hash() uint64 {
+	h := fnv.New64a()
+	b := make([]byte, binary.MaxVarintLen64)
+	put := func(x int) {
+		n := binary.PutVarint(b, int64(x))
+		h.Write(b[:n])
+	}
+	put(a.Tick)
+	for _, color := range a.Colors() {
+		h.Write([]byte(color))
+		put(len(a.Snakes[color]))
+		for _, p := range a.Snakes[color] {
+			put(p.X)
+			put(p.Y)
 		}
+	}
+	for _, l := range a.Losers {
+		h.Write([]byte(l.Color))
+		h.Write([]byte(l.CollideWith))
+		put(l.Tick)
+	}
+	return h.Sum64()
+}
 
-		var p Point
-		last := snake[len(snake)-1]
-		switch dirt {
-		case DirectionUp:
-			p = Point{X: last.X, Y: last.Y + 1}
-		case DirectionDown:
-			p = Point{X: last.X, Y: last.Y - 1}
-		case DirectionLeft:
-			p = Point{X: last.X - 1, Y: last.Y}
-		case DirectionRight:
-			p = Point{X: last.X + 1, Y: last.Y}
+func (a *Arena) lost(color Color) bool {
+	for _, l := range a.Losers {
+		if l.Color == color {
+			return true
 		}
+	}
+	return false
+}
+
+func (a *Arena) outside(p Point) bool {
+	return p.X <= 0 || p.X >= a.Size.X || p.Y <= 0 || p.Y >= a.Size.Y
+}
 
-		// Check for collision with the wall
-		if p.X <= 0 || p.X >= a.Size.X || p.Y <= 0 || p.Y >= a.Size.Y {
-			a.Losers = append(a.Losers, Loser{Color: color, CollideWith: ColorWall})
+// Update updates the state of an arena for a timestep.
+// All snakes move simultaneously: the next cell of every snake is computed first, and collisions are resolved afterwards by the ruleset.
+func (a *Arena) Update(acts map[Color]Direction) {
+	a.Tick += 1
+	steps := make([]Step, 0, len(a.Snakes))
+	for _, color := range a.Colors() {
+		if a.lost(color) {
 			continue
 		}
-		if dirt != prevDirt {
-			a.Snakes[color] = append(snake, p)
-		} else {
-			a.Snakes[color][len(snake)-1] = p
-		}
+		steps = append(steps, a.Rules.Step(a, color, acts[color]))
+	}
 
-		// Check collisions
-		shouldProfile := false
-		if rand.Intn(60) == 0 {
-			shouldProfile = true
-		}
-		var t int64
-		if shouldProfile {
-			t = time.Now().UnixNano()
-		}
-		for otherColor, points := range a.Points {
-			_, ok := points[p]
-			if ok {
-				a.Losers = append(a.Losers, Loser{Color: color, CollideWith: otherColor})
-				break
-			}
+	// Check collisions
+	shouldProfile := false
+	if a.Tick%60 == 0 {
+		shouldProfile = true
+	}
+	var t int64
+	if shouldProfile {
+		t = time.Now().UnixNano()
+	}
+	losers := a.Rules.Collide(a, steps)
+	if shouldProfile {
+		glog.Infof("collision time spent: %d ns", time.Now().UnixNano()-t)
+	}
+
+	for _, s := range steps {
+		if a.outside(s.To) {
+			continue
 		}
-		if shouldProfile {
-			glog.Infof("collision time spent: %d ns", time.Now().UnixNano()-t)
+		snake := a.Snakes[s.Color]
+		if s.Turn {
+			a.Snakes[s.Color] = append(snake, s.To)
+		} else {
+			snake[len(snake)-1] = s.To
 		}
-		a.Points[color][p] = struct{}{}
+		a.Points[s.Color][s.To] = struct{}{}
 	}
+	a.Losers = append(a.Losers, losers...)
 }
 
 type Player struct {
-	Arena     chan *Arena
-	GameEnd   chan Color // this is the color of the winner
+	Identity Identity
+
+	Arena     chan Snapshot
+	GameEnd   chan GameResult
 	Countdown chan int
 }
 
+// RoomConfig is the configuration of a room, chosen by the player who creates it.
+type RoomConfig struct {
+	MaxPlayers int
+	MinPlayers int
+
+	// LobbyTimeout is how long a game waits for more players once MinPlayers are ready.
+	// A game starts right away when MaxPlayers are ready.
+	LobbyTimeout time.Duration
+
+	Rules Ruleset
+
+	// TickRate is the number of timesteps per second.
+	TickRate int
+
+	// Bots is the name of the bot which fills the empty seats of a game once LobbyTimeout expires after the first player is ready.
+	// Several bots are separated by commas, and fill the seats in order, the last one filling the remaining seats.
+	// Empty seats are not filled if Bots is empty.
+	Bots string
+}
+
+var DefaultRoomConfig = RoomConfig{
+	MaxPlayers:   4,
+	MinPlayers:   2,
+	LobbyTimeout: 10 * time.Second,
+	Rules:        Rulesets["classic"],
+	TickRate:     DefaultTickRate,
+}
+
+// Validate checks the config, filling unset fields with their default values.
+func (c *RoomConfig) Validate() error {
+	if c.MaxPlayers == 0 {
+		c.MaxPlayers = DefaultRoomConfig.MaxPlayers
+	}
+	if c.MinPlayers == 0 {
+		c.MinPlayers = DefaultRoomConfig.MinPlayers
+		if c.MinPlayers > c.MaxPlayers {
+			c.MinPlayers = c.MaxPlayers
+		}
+	}
+	if c.Rules == nil {
+		c.Rules = DefaultRoomConfig.Rules
+	}
+	if c.TickRate == 0 {
+		c.TickRate = DefaultRoomConfig.TickRate
+	}
+
+	if c.MaxPlayers < 2 || c.MaxPlayers > len(Colors) {
+		return fmt.Errorf("max players must be between 2 and %d", len(Colors))
+	}
+	if c.MinPlayers < 2 || c.MinPlayers > c.MaxPlayers {
+		return fmt.Errorf("min players must be between 2 and max players")
+	}
+	if c.LobbyTimeout < 0 || c.LobbyTimeout > 5*time.Minute {
+		return fmt.Errorf("lobby timeout must be between 0 and 5 minutes")
+	}
+	if c.TickRate < MinTickRate || c.TickRate > MaxTickRate {
+		return fmt.Errorf("tick rate must be between %d and %d", MinTickRate, MaxTickRate)
+	}
+	if c.Bots != "" {
+		for _, name := range strings.Split(c.Bots, ",") {
+			if _, ok := Bots[name]; !ok {
+				return fmt.Errorf("unknown bot %q", name)
+			}
+		}
+	}
+	return nil
+}
+
 type Room struct {
 	sync.RWMutex
-	Players    map[*Player]struct{}
-	MaxPlayers int
-	Game       *Game
+	Name string
+	RoomConfig
+	Players map[*Player]struct{}
+	Game    *Game
+
+	// running is the last game started in the room.
+	running *Game
 
+	// Watchers are the spectators of the room, guarded by the room lock since running games broadcast to them.
 	Watchers map[*Player]struct{}
 }
 
-func NewRoom(maxPlayers int) *Room {
+func NewRoom(name string, cfg RoomConfig) *Room {
 	r := Room{
+		Name:       name,
+		RoomConfig: cfg,
 		Players:    make(map[*Player]struct{}),
-		MaxPlayers: maxPlayers,
 		Watchers:   make(map[*Player]struct{}),
 	}
 	return &r
@@ -227,7 +470,8 @@ func (r *Room) Ready(player *Player) (*Game, Color) {
 	r.Lock()
 	defer r.Unlock()
 	if r.Game == nil {
-		r.Game = NewGame(r.MaxPlayers)
+		r.Game = NewGame(r.RoomConfig)
+		r.Game.Room = r.Name
 	}
 	game := r.Game
 
@@ -240,32 +484,134 @@ func (r *Room) Ready(player *Player) (*Game, Color) {
 	}
 	game.Players[color] = player
 
-	if len(game.Players) >= game.MinPlayers {
-		game.Watchers = r.Watchers
-		go game.Start()
-		r.Game = nil
+	n := len(game.Players)
+	switch {
+	case n >= r.MaxPlayers:
+		r.start()
+	case r.LobbyTimeout == 0 && (n >= game.MinPlayers || r.Bots != ""):
+		r.fillBots()
+		r.start()
+	case n == game.MinPlayers || (n == 1 && r.Bots != ""):
+		time.AfterFunc(r.LobbyTimeout, func() {
+			r.Lock()
+			defer r.Unlock()
+			if r.Game != game {
+				return
+			}
+			r.fillBots()
+			if len(game.Players) >= game.MinPlayers {
+				r.start()
+			}
+		})
 	}
 	return game, color
 }
 
+// fillBots seats a bot in each empty seat of the game waiting to start, if the room has bots.
+func (r *Room) fillBots() {
+	if r.Bots == "" {
+		return
+	}
+	names := strings.Split(r.Bots, ",")
+	game := r.Game
+	seated := 0
+	for i, c := range Colors {
+		if len(game.Players) >= r.MaxPlayers {
+			break
+		}
+		if _, ok := game.Players[c]; ok {
+			continue
+		}
+		name := names[len(names)-1]
+		if seated < len(names) {
+			name = names[seated]
+		}
+		seated += 1
+		me := &Player{
+			Identity:  BotIdentity(name),
+			Arena:     make(chan Snapshot, 32),
+			GameEnd:   make(chan GameResult, 4),
+			Countdown: make(chan int, 4),
+		}
+		game.Players[c] = me
+		bot := Bots[name](rand.New(rand.NewSource(game.Seed + int64(i))))
+		go playBot(game, c, bot, me)
+	}
+}
+
+// Unready removes player from the game waiting to start, if any.
+func (r *Room) Unready(player *Player) {
+	r.Lock()
+	defer r.Unlock()
+	if r.Game == nil {
+		return
+	}
+	for color, p := range r.Game.Players {
+		if p == player {
+			delete(r.Game.Players, color)
+		}
+	}
+}
+
+// Watch adds player to the spectators of the room.
+func (r *Room) Watch(player *Player) {
+	r.Lock()
+	defer r.Unlock()
+	r.Watchers[player] = struct{}{}
+}
+
+// Unwatch removes player from the spectators of the room.
+func (r *Room) Unwatch(player *Player) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.Watchers, player)
+}
+
+// watchers returns a copy of the spectators of the room.
+func (r *Room) watchers() []*Player {
+	r.RLock()
+	defer r.RUnlock()
+	watchers := make([]*Player, 0, len(r.Watchers))
+	for p, _ := range r.Watchers {
+		watchers = append(watchers, p)
+	}
+	return watchers
+}
+
+func (r *Room) start() {
+	game := r.Game
+	game.room = r
+	game.setState(RoomCountdown)
+	go game.Start()
+	r.running = game
+	r.Game = nil
+}
+
+var ErrRoomFull = fmt.Errorf("max players reached")
+
 type Hall struct {
 	sync.RWMutex
 	m map[string]*Room
 }
 
-func (h *Hall) EnterRoom(name string, player *Player) (*Room, error) {
+// EnterRoom enters the room called name, creating it with cfg if it does not exist.
+func (h *Hall) EnterRoom(name string, cfg RoomConfig, player *Player) (*Room, error) {
 	h.Lock()
 	defer h.Unlock()
 	room, ok := h.m[name]
 	if !ok {
-		room = NewRoom(4)
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+		room = NewRoom(name, cfg)
 		h.m[name] = room
 	}
 
 	if len(room.Players) >= room.MaxPlayers {
-		return nil, fmt.Errorf("max players reached")
+		return nil, ErrRoomFull
 	}
 	room.Players[player] = struct{}{}
+	lobbyChanges.Notify()
 
 	return room, nil
 }
@@ -279,9 +625,11 @@ func (h *Hall) LeaveRoom(name string, player *Player) {
 	}
 
 	delete(room.Players, player)
+	room.Unready(player)
 	if len(room.Players) == 0 {
 		delete(h.m, name)
 	}
+	lobbyChanges.Notify()
 }
 
 func (h *Hall) WatchRoom(name string, player *Player) error {
@@ -292,7 +640,8 @@ func (h *Hall) WatchRoom(name string, player *Player) error {
 		return fmt.Errorf("no such room")
 	}
 
-	room.Watchers[player] = struct{}{}
+	room.Watch(player)
+	lobbyChanges.Notify()
 
 	return nil
 }
@@ -305,41 +654,106 @@ func (h *Hall) UnwatchRoom(name string, player *Player) error {
 		return fmt.Errorf("no such room")
 	}
 
-	delete(room.Watchers, player)
+	room.Unwatch(player)
+	lobbyChanges.Notify()
 
 	return nil
 }
 
+// TickStats tracks how closely the game loop keeps to its timestep.
+type TickStats struct {
+	Ticks int
+	// Late is the number of ticks which overran by more than a timestep.
+	Late       int
+	MaxOverrun time.Duration
+}
+
+func (s *TickStats) add(overrun, period time.Duration) {
+	s.Ticks += 1
+	if overrun > period {
+		s.Late += 1
+	}
+	if overrun > s.MaxOverrun {
+		s.MaxOverrun = overrun
+	}
+}
+
 type Game struct {
+	ID         string
+	Room       string
 	Players    map[Color]*Player
 	MinPlayers int
+	Rules      Ruleset
+	TickRate   int
+	Seed       int64
 	Move       chan MoveCmd
+	Stats      TickStats
 
-	Watchers map[*Player]struct{}
+	// last is the last snapshot broadcast, which the next one is a delta of.
+	last *Snapshot
+
+	// state is the RoomState of the game once started, read by the lobby while the game runs.
+	state atomic.Value
+
+	// room is the room the game was started in, whose spectators watch the game.
+	room *Room
 }
 
-func NewGame(minPlayers int) *Game {
+func NewGame(cfg RoomConfig) *Game {
 	game := Game{
 		Players:    make(map[Color]*Player),
-		MinPlayers: minPlayers,
-		Move:       make(chan MoveCmd),
+		MinPlayers: cfg.MinPlayers,
+		Rules:      cfg.Rules,
+		TickRate:   cfg.TickRate,
+		Seed:       time.Now().UnixNano(),
+		Move:       make(chan MoveCmd, 64),
 	}
+	game.ID = strconv.FormatInt(game.Seed, 36)
 	return &game
 }
 
-func (g *Game) Ended(a *Arena) bool {
-	if len(g.Players)-1 <= len(a.Losers) {
-		return true
+// colors returns the colors of the players in the order of Colors.
+func (g *Game) colors() []Color {
+	colors := make([]Color, 0, len(g.Players))
+	for color, _ := range g.Players {
+		colors = append(colors, color)
 	}
-	return false
+	SortColors(colors)
+	return colors
+}
 
-	// TODO
-	for _, snake := range a.Snakes {
-		if len(snake) > 5 {
-			return true
-		}
+// State returns the state of a started game, RoomWaiting once it ended.
+func (g *Game) State() RoomState {
+	if s, ok := g.state.Load().(RoomState); ok {
+		return s
 	}
-	return false
+	return RoomWaiting
+}
+
+func (g *Game) setState(s RoomState) {
+	g.state.Store(s)
+	lobbyChanges.Notify()
+}
+
+// identities returns the identities of the players by color.
+func (g *Game) identities() map[Color]Identity {
+	players := make(map[Color]Identity)
+	for color, p := range g.Players {
+		players[color] = p.Identity
+	}
+	return players
+}
+
+// watchers returns the spectators of the game, none if it was not started in a room.
+func (g *Game) watchers() []*Player {
+	if g.room == nil {
+		return nil
+	}
+	return g.room.watchers()
+}
+
+func (g *Game) Ended(a *Arena) bool {
+	return g.Rules.Ended(a, g.colors())
 }
 
 func (g *Game) broadcastCountdown(cnt int) {
@@ -349,7 +763,7 @@ func (g *Game) broadcastCountdown(cnt int) {
 		default:
 		}
 	}
-	for p, _ := range g.Watchers {
+	for _, p := range g.watchers() {
 		select {
 		case p.Countdown <- cnt:
 		default:
@@ -357,55 +771,74 @@ func (g *Game) broadcastCountdown(cnt int) {
 	}
 }
 
-func (g *Game) broadcastArena(arena *Arena) {
+// broadcastArena sends a snapshot of arena, taking moves for the next tick until deadline.
+func (g *Game) broadcastArena(arena *Arena, deadline time.Time) {
+	snapshot := NewSnapshot(arena, g.identities(), g.last)
+	snapshot.Deadline = deadline
+	g.last = &snapshot
 	for _, p := range g.Players {
 		select {
-		case p.Arena <- arena:
+		case p.Arena <- snapshot:
 		default:
 		}
 	}
-	for p, _ := range g.Watchers {
+	for _, p := range g.watchers() {
 		select {
-		case p.Arena <- arena:
+		case p.Arena <- snapshot:
 		default:
 		}
 	}
 }
 
-func (g *Game) broadcastGameEnd(arena *Arena) {
-	var winner Color
-	for color, _ := range g.Players {
-		lost := false
-		for _, loser := range arena.Losers {
-			if loser.Color == color {
-				lost = true
-			}
-		}
-		if !lost {
-			winner = color
-			break
-		}
+func (g *Game) broadcastGameEnd(arena *Arena, rec *Recording) GameResult {
+	result := NewGameResult(g.colors(), arena)
+	result.Players = g.identities()
+	if rec != nil {
+		result.Replay = rec.ID
 	}
 
 	for _, p := range g.Players {
 		select {
-		case p.GameEnd <- winner:
+		case p.GameEnd <- result:
 		default:
 		}
 	}
-	for p, _ := range g.Watchers {
+	for _, p := range g.watchers() {
 		select {
-		case p.GameEnd <- winner:
+		case p.GameEnd <- result:
 		default:
 		}
 	}
+	return result
 }
 
-var initColors = []Point{
-	Point{X: 333, Y: 200},
-	Point{X: 666, Y: 200},
-	Point{X: 333, Y: 400},
-	Point{X: 666, Y: 400},
+// saveMatch records the result of the game, which began at start, in the match history, and updates the ratings of its players.
+func (g *Game) saveMatch(start time.Time, arena *Arena, result GameResult) {
+	m := NewMatch(g.ID, g.Room, g.Rules.Name(), start, time.Now(), arena.Tick, result)
+	if Matches != nil {
+		if err := Matches.Save(m); err != nil {
+			glog.Errorf("saving match %s: %v", g.ID, err)
+		}
+	}
+	if Ratings != nil {
+		Ratings.Record(m)
+	}
+}
+
+// queueMove appends d to the moves queued for snake, unless the queue is full or d repeats or reverses the direction the snake has once the queued moves are applied.
+// Such a move would be ignored when applied, delaying the moves queued after it by a tick.
+func queueMove(q []Direction, snake []Point, d Direction) []Direction {
+	if len(q) >= MaxQueuedMoves || !validDirection(d) || len(snake) < 2 {
+		return q
+	}
+	dirt := computeDirection(snake)
+	if len(q) > 0 {
+		dirt = q[len(q)-1]
+	}
+	if d == dirt || oppositeDirections(d, dirt) {
+		return q
+	}
+	return append(q, d)
 }
 
 func (g *Game) Start() {
@@ -421,25 +854,26 @@ func (g *Game) Start() {
 	}()
 
 	// Select initial direction
-	snakes := make(map[Color][]Point)
 	var ratio float64 = DefaultSizeRatio
-	i := 0
-	for color, _ := range g.Players {
-		s := make([]Point, 2)
-		s[0] = Point{X: int(float64(initColors[i].X) / ratio), Y: int(float64(initColors[i].Y) / ratio)}
-		s[1] = Point{s[0].X + 1, s[0].Y}
-		snakes[color] = s
-		i += 1
+	snakes, err := g.Rules.Spawn(g.colors(), ArenaSize(ratio), rand.New(rand.NewSource(g.Seed)))
+	if err != nil {
+		glog.Errorf("game %s: %v", g.ID, err)
+		wg.Wait()
+		g.broadcastGameEnd(NewArena(g.Rules, make(map[Color][]Point), ratio, nil), nil)
+		g.setState(RoomWaiting)
+		return
 	}
-	arena := NewArena(snakes, ratio)
+	arena := NewArena(g.Rules, snakes, ratio, rand.New(rand.NewSource(g.Seed)))
 
-	timer := time.After(3 * time.Second)
+	start := time.Now().Add(3 * time.Second)
+	timer := time.After(start.Sub(time.Now()))
+	g.broadcastArena(arena, start)
 InitDirt:
 	for {
 		select {
 		case cmd := <-g.Move:
 			if ok := arena.ChangeInitDirt(cmd); ok {
-				g.broadcastArena(arena)
+				g.broadcastArena(arena, start)
 			}
 		case <-timer:
 			break InitDirt
@@ -449,23 +883,65 @@ InitDirt:
 	g.broadcastCountdown(0)
 
 	// Game begins!
+	g.setState(RoomPlaying)
+	begin := time.Now()
+	var rec *Recording
+	if Replays != nil {
+		rec = NewRecording(g.ID, arena, g.Seed, g.TickRate)
+		rec.Players = g.identities()
+	}
+	queues := make(map[Color][]Direction)
+	period := TickPeriod(g.TickRate)
+	next := time.Now().Add(period)
 	for {
-		acts := make(map[Color]Direction)
-		timer = time.After(50 * time.Millisecond)
+		timer = time.After(next.Sub(time.Now()))
 	CollectActs:
 		for {
 			select {
 			case cmd := <-g.Move:
-				acts[cmd.Color] = cmd.Direction
+				queues[cmd.Color] = queueMove(queues[cmd.Color], arena.Snakes[cmd.Color], cmd.Direction)
 			case <-timer:
 				break CollectActs
 			}
 		}
+
+		// Keep a fixed timestep: a late tick is followed by ticks run back to back until the loop catches up.
+		overrun := time.Now().Sub(next)
+		g.Stats.add(overrun, period)
+		next = next.Add(period)
+		if overrun > MaxCatchUpTicks*period {
+			glog.Warningf("game %s: tick %d overran by %v, skipping ahead", g.ID, arena.Tick+1, overrun)
+			next = time.Now().Add(period)
+		}
+
+		acts := make(map[Color]Direction)
+		for color, q := range queues {
+			if len(q) > 0 {
+				acts[color] = q[0]
+				queues[color] = q[1:]
+			}
+		}
 		arena.Update(acts)
-		g.broadcastArena(arena)
+		if rec != nil {
+			rec.Acts = append(rec.Acts, acts)
+		}
+		if glog.V(2) {
+			glog.Infof("tick %d state %x", arena.Tick, arena.Hash())
+		}
+		g.broadcastArena(arena, next)
 
 		if g.Ended(arena) {
-			g.broadcastGameEnd(arena)
+			if rec != nil {
+				rec.Hash = arena.Hash()
+				if err := Replays.Save(rec); err != nil {
+					glog.Errorf("saving replay %s: %v", rec.ID, err)
+					rec = nil
+				}
+			}
+			result := g.broadcastGameEnd(arena, rec)
+			g.setState(RoomWaiting)
+			g.saveMatch(begin, arena, result)
+			glog.Infof("game %s: %d ticks, %d late, max overrun %v", g.ID, g.Stats.Ticks, g.Stats.Late, g.Stats.MaxOverrun)
 			return
 		}
 	}
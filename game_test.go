@@ -0,0 +1,160 @@
+package tron
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func newTestArena(snakes map[Color][]Point) *Arena {
+	return NewArena(Classic{}, snakes, DefaultSizeRatio, rand.New(rand.NewSource(1)))
+}
+
+func TestHeadOnDraw(t *testing.T) {
+	red, blue := Colors[0], Colors[1]
+	tests := []struct {
+		name   string
+		snakes map[Color][]Point
+	}{
+		{
+			// Both heads move into (12, 10).
+			name:   "same cell",
+			snakes: map[Color][]Point{red: {{10, 10}, {11, 10}}, blue: {{14, 10}, {13, 10}}},
+		},
+		{
+			// The heads at (11, 10) and (12, 10) swap places.
+			name:   "swap",
+			snakes: map[Color][]Point{red: {{10, 10}, {11, 10}}, blue: {{13, 10}, {12, 10}}},
+		},
+	}
+	for _, tt := range tests {
+		a := newTestArena(tt.snakes)
+		a.Update(nil)
+
+		if len(a.Losers) != 2 {
+			t.Errorf("%s: got losers %+v, want both snakes", tt.name, a.Losers)
+			continue
+		}
+		for _, l := range a.Losers {
+			if !l.HeadOn || l.Tick != 1 {
+				t.Errorf("%s: got loser %+v, want a head-on crash on tick 1", tt.name, l)
+			}
+		}
+		result := NewGameResult(a.Colors(), a)
+		if !result.Draw || result.Winner != "" {
+			t.Errorf("%s: got winner %q draw %v, want a draw", tt.name, result.Winner, result.Draw)
+		}
+		for _, p := range result.Placements {
+			if p.Place != 1 || p.Cause() != "head-on" {
+				t.Errorf("%s: got placement %+v, want a shared first place", tt.name, p)
+			}
+		}
+	}
+}
+
+func TestWallCrash(t *testing.T) {
+	red, blue := Colors[0], Colors[1]
+	a := newTestArena(map[Color][]Point{red: {{3, 10}, {2, 10}}, blue: {{20, 20}, {21, 20}}})
+	a.Update(nil)
+	a.Update(nil)
+
+	result := NewGameResult(a.Colors(), a)
+	if result.Draw || result.Winner != blue {
+		t.Fatalf("got winner %q draw %v, want %q", result.Winner, result.Draw, blue)
+	}
+	if p := result.Placements[1]; p.Color != red || p.Place != 2 || p.Tick != 2 || p.Cause() != "wall" {
+		t.Errorf("got placement %+v, want %q second after hitting the wall on tick 2", p, red)
+	}
+}
+
+func TestQueueMove(t *testing.T) {
+	// The snake heads right.
+	snake := []Point{{10, 10}, {11, 10}}
+	tests := []struct {
+		name  string
+		moves []Direction
+		want  []Direction
+	}{
+		{"held key then turn", []Direction{DirectionRight, DirectionRight, DirectionUp}, []Direction{DirectionUp}},
+		{"reverse", []Direction{DirectionLeft, DirectionDown}, []Direction{DirectionDown}},
+		{"double turn", []Direction{DirectionUp, DirectionUp, DirectionLeft, DirectionRight, DirectionDown}, []Direction{DirectionUp, DirectionLeft, DirectionDown}},
+		{"invalid", []Direction{"sideways", DirectionUp}, []Direction{DirectionUp}},
+		{"full", []Direction{DirectionUp, DirectionLeft, DirectionDown, DirectionRight, DirectionUp}, []Direction{DirectionUp, DirectionLeft, DirectionDown, DirectionRight}},
+	}
+	for _, tt := range tests {
+		var q []Direction
+		for _, d := range tt.moves {
+			q = queueMove(q, snake, d)
+		}
+		if !reflect.DeepEqual(q, tt.want) {
+			t.Errorf("%s: got queue %v, want %v", tt.name, q, tt.want)
+		}
+	}
+}
+
+// playScript plays a game between colors with moves drawn from seed, and returns the hash of the arena after each tick.
+func playScript(colors []Color, seed int64, ticks int) []uint64 {
+	snakes, err := Classic{}.Spawn(colors, ArenaSize(DefaultSizeRatio), rand.New(rand.NewSource(seed)))
+	if err != nil {
+		panic(err)
+	}
+	a := NewArena(Classic{}, snakes, DefaultSizeRatio, rand.New(rand.NewSource(seed)))
+	moves := rand.New(rand.NewSource(seed))
+	directions := []Direction{"", DirectionUp, DirectionDown, DirectionLeft, DirectionRight}
+	hashes := make([]uint64, 0, ticks)
+	for i := 0; i < ticks; i++ {
+		acts := make(map[Color]Direction)
+		for _, color := range colors {
+			acts[color] = directions[moves.Intn(len(directions))]
+		}
+		a.Update(acts)
+		hashes = append(hashes, a.Hash())
+	}
+	return hashes
+}
+
+func TestHashReproducible(t *testing.T) {
+	colors := Colors[:4]
+	a, b := playScript(colors, 42, 200), playScript(colors, 42, 200)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("tick %d: got hashes %x and %x for the same seed and moves", i+1, a[i], b[i])
+		}
+	}
+
+	other := playScript(colors, 43, 200)
+	if other[len(other)-1] == a[len(a)-1] {
+		t.Errorf("got the same final hash %x for different moves", a[len(a)-1])
+	}
+}
+
+func TestReplay(t *testing.T) {
+	bots := make(map[Color]Bot)
+	for i, color := range Colors[:3] {
+		bots[color] = Bots["random"](rand.New(rand.NewSource(int64(i))))
+	}
+	arena, rec, err := Simulate(Classic{}, bots, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Hash != arena.Hash() {
+		t.Fatalf("got recorded hash %x, want %x", rec.Hash, arena.Hash())
+	}
+
+	ticks := 0
+	replayed, err := rec.Replay(func(a *Arena) error {
+		ticks += 1
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Hash() != arena.Hash() || ticks != arena.Tick {
+		t.Errorf("got %d ticks and hash %x, want %d ticks and hash %x", ticks, replayed.Hash(), arena.Tick, arena.Hash())
+	}
+
+	rec.Acts = rec.Acts[:len(rec.Acts)-1]
+	if _, err := rec.Replay(func(a *Arena) error { return nil }); err == nil {
+		t.Errorf("replay of a truncated recording did not diverge")
+	}
+}
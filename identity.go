@@ -0,0 +1,107 @@
+package tron
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Identity is who plays a color.
+type Identity struct {
+	// ID is the persistent ID of a player, or bot:<name> for a bot.
+	ID       string
+	Nickname string
+}
+
+// BotIdentity returns the identity of the built-in or program bot called name.
+func BotIdentity(name string) Identity {
+	return Identity{ID: botIDPrefix + name, Nickname: name}
+}
+
+const botIDPrefix = "bot:"
+
+// IsBotID reports whether id is the ID of a built-in or program bot, which all the games of the bot share.
+func IsBotID(id string) bool {
+	return strings.HasPrefix(id, botIDPrefix)
+}
+
+// IdentitySecret is the key signing the tokens which prove the IDs of players.
+// It is read from the IDENTITY_SECRET environment variable or loaded by LoadIdentitySecret, and is random otherwise, in which case tokens do not outlive the server.
+var IdentitySecret, ephemeralSecret = identitySecret()
+
+func identitySecret() ([]byte, bool) {
+	if s := os.Getenv("IDENTITY_SECRET"); s != "" {
+		return []byte(s), false
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b, true
+}
+
+// EphemeralIdentitySecret reports whether IdentitySecret is random, so that players get new IDs when the server restarts.
+func EphemeralIdentitySecret() bool {
+	return ephemeralSecret
+}
+
+// LoadIdentitySecret sets IdentitySecret to the key in the file at path, creating the file with a random key if it does not exist.
+func LoadIdentitySecret(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		b = []byte(hex.EncodeToString(key) + "\n")
+		err = ioutil.WriteFile(path, b, 0600)
+	}
+	if err != nil {
+		return err
+	}
+	secret := bytes.TrimSpace(b)
+	if len(secret) == 0 {
+		return fmt.Errorf("%s: empty identity secret", path)
+	}
+	IdentitySecret, ephemeralSecret = secret, false
+	return nil
+}
+
+// NewPlayerID returns a new random player ID.
+func NewPlayerID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func signID(id string) string {
+	mac := hmac.New(sha256.New, IdentitySecret)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IdentityToken returns the token proving the player ID id, which players send when joining to keep their ID.
+func IdentityToken(id string) string {
+	return id + "." + signID(id)
+}
+
+// ParseIdentityToken returns the player ID proven by token.
+func ParseIdentityToken(token string) (string, error) {
+	i := strings.LastIndex(token, ".")
+	if i <= 0 {
+		return "", fmt.Errorf("malformed identity token")
+	}
+	id, sig := token[:i], token[i+1:]
+	if IsBotID(id) || !hmac.Equal([]byte(sig), []byte(signID(id))) {
+		return "", fmt.Errorf("invalid identity token")
+	}
+	return id, nil
+}
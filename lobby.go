@@ -0,0 +1,89 @@
+package tron
+
+import (
+	"sort"
+	"sync"
+)
+
+// RoomState is what a room is doing, as shown in the lobby.
+type RoomState string
+
+const (
+	// RoomWaiting is a room waiting for players to start its next game.
+	RoomWaiting   RoomState = "waiting"
+	RoomCountdown RoomState = "countdown"
+	RoomPlaying   RoomState = "playing"
+)
+
+// RoomInfo describes a room in the lobby.
+type RoomInfo struct {
+	Name       string
+	Players    int
+	MaxPlayers int
+	Spectators int
+	State      RoomState
+}
+
+// notifier signals its subscribers that something changed.
+// Subscribers are signaled through a channel buffering one signal, so that changes coming in bursts are coalesced.
+type notifier struct {
+	sync.Mutex
+	subs map[chan struct{}]struct{}
+}
+
+func (n *notifier) Subscribe() chan struct{} {
+	n.Lock()
+	defer n.Unlock()
+	c := make(chan struct{}, 1)
+	n.subs[c] = struct{}{}
+	return c
+}
+
+func (n *notifier) Unsubscribe(c chan struct{}) {
+	n.Lock()
+	defer n.Unlock()
+	delete(n.subs, c)
+}
+
+func (n *notifier) Notify() {
+	n.Lock()
+	defer n.Unlock()
+	for c, _ := range n.subs {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// lobbyChanges is notified whenever a room is created, entered or left, and whenever a game changes state.
+var lobbyChanges = &notifier{subs: make(map[chan struct{}]struct{})}
+
+// Info describes the room for the lobby, whose state is the state of the last game started in the room, or RoomWaiting if it ended.
+func (r *Room) Info() RoomInfo {
+	r.RLock()
+	defer r.RUnlock()
+	info := RoomInfo{
+		Name:       r.Name,
+		Players:    len(r.Players),
+		MaxPlayers: r.MaxPlayers,
+		Spectators: len(r.Watchers),
+		State:      RoomWaiting,
+	}
+	if r.running != nil {
+		info.State = r.running.State()
+	}
+	return info
+}
+
+// Rooms returns the rooms of the hall, sorted by name.
+func (h *Hall) Rooms() []RoomInfo {
+	h.RLock()
+	defer h.RUnlock()
+	rooms := make([]RoomInfo, 0, len(h.m))
+	for _, room := range h.m {
+		rooms = append(rooms, room.Info())
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Name < rooms[j].Name })
+	return rooms
+}
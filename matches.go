@@ -0,0 +1,197 @@
+package tron
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Match is the record of a finished game.
+type Match struct {
+	ID    string
+	Room  string
+	Rules string
+	Start time.Time
+	End   time.Time
+	Ticks int
+
+	// Winner is empty when the game ended in a draw.
+	Winner Color
+	Draw   bool
+	// Players are in the order of their placements.
+	Players []MatchPlayer
+
+	// Replay is the ID of the recording of the game, empty if it was not recorded.
+	Replay string
+}
+
+// MatchPlayer is how a player fared in a match.
+type MatchPlayer struct {
+	ID       string
+	Nickname string
+	Color    Color
+	Place    int
+
+	// Tick is the tick on which the player was eliminated, or zero if the player survived.
+	Tick        int
+	CollideWith Color
+	HeadOn      bool
+	// Cause is why the player was eliminated, as returned by Placement.Cause.
+	Cause string
+}
+
+// NewMatch returns the record of a game played in room from start to end, which ended with result after ticks timesteps.
+func NewMatch(id, room, rules string, start, end time.Time, ticks int, result GameResult) *Match {
+	m := Match{
+		ID:      id,
+		Room:    room,
+		Rules:   rules,
+		Start:   start,
+		End:     end,
+		Ticks:   ticks,
+		Winner:  result.Winner,
+		Draw:    result.Draw,
+		Players: make([]MatchPlayer, 0, len(result.Placements)),
+		Replay:  result.Replay,
+	}
+	for _, p := range result.Placements {
+		id := result.Players[p.Color]
+		m.Players = append(m.Players, MatchPlayer{
+			ID:          id.ID,
+			Nickname:    id.Nickname,
+			Color:       p.Color,
+			Place:       p.Place,
+			Tick:        p.Tick,
+			CollideWith: p.CollideWith,
+			HeadOn:      p.HeadOn,
+			Cause:       p.Cause(),
+		})
+	}
+	return &m
+}
+
+// Played reports whether the player of ID id played in the match.
+func (m *Match) Played(id string) bool {
+	for _, p := range m.Players {
+		if p.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+type MatchStore interface {
+	Save(m *Match) error
+	// Matches returns up to limit matches, the most recent first, played by the player of ID id, or by anyone if id is empty.
+	Matches(id string, limit int) ([]*Match, error)
+}
+
+// Matches is where finished games are recorded, nil disables the match history.
+var Matches MatchStore = NewMemMatchStore(1024)
+
+// MemMatchStore keeps the most recent matches in memory.
+type MemMatchStore struct {
+	sync.RWMutex
+	matches []*Match
+	max     int
+}
+
+func NewMemMatchStore(max int) *MemMatchStore {
+	return &MemMatchStore{matches: make([]*Match, 0, max), max: max}
+}
+
+func (s *MemMatchStore) Save(m *Match) error {
+	s.Lock()
+	defer s.Unlock()
+	s.matches = append(s.matches, m)
+	if s.max > 0 && len(s.matches) > s.max {
+		s.matches = s.matches[len(s.matches)-s.max:]
+	}
+	return nil
+}
+
+func (s *MemMatchStore) Matches(id string, limit int) ([]*Match, error) {
+	s.RLock()
+	defer s.RUnlock()
+	matches := make([]*Match, 0)
+	for i := len(s.matches) - 1; i >= 0 && len(matches) < limit; i-- {
+		if id == "" || s.matches[i].Played(id) {
+			matches = append(matches, s.matches[i])
+		}
+	}
+	return matches, nil
+}
+
+// FileMatchStore appends each match as a line of JSON to a file, and keeps every match in memory to answer queries.
+type FileMatchStore struct {
+	mem  *MemMatchStore
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileMatchStore opens the match history in the file at path, creating it if it does not exist.
+// A last line cut short, as left by a crash while saving a match, is dropped.
+func NewFileMatchStore(path string) (*FileMatchStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s := FileMatchStore{mem: NewMemMatchStore(0), file: f}
+	if err := s.load(path); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *FileMatchStore) load(path string) error {
+	r := bufio.NewReader(s.file)
+	var offset int64
+	for line := 1; ; line++ {
+		b, err := r.ReadBytes('\n')
+		if err == io.EOF && len(b) == 0 {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		m := &Match{}
+		if jerr := json.Unmarshal(b, m); jerr != nil {
+			if err != io.EOF {
+				return fmt.Errorf("%s:%d: %v", path, line, jerr)
+			}
+			glog.Warningf("%s:%d: dropping truncated match: %v", path, line, jerr)
+			return s.file.Truncate(offset)
+		}
+		s.mem.Save(m)
+		if err == io.EOF {
+			// The match is whole but its newline is missing, which the next match must not be appended to.
+			_, err := s.file.Write([]byte{'\n'})
+			return err
+		}
+		offset += int64(len(b))
+	}
+}
+
+func (s *FileMatchStore) Save(m *Match) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return s.mem.Save(m)
+}
+
+func (s *FileMatchStore) Matches(id string, limit int) ([]*Match, error) {
+	return s.mem.Matches(id, limit)
+}
@@ -0,0 +1,154 @@
+package tron
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Matchmaker groups the players waiting for a quick match by rating, and seats each group in a new room.
+// A player is matched with the players whose rating is within a band around its own, which widens the longer it waits.
+// Players who waited MaxWait are seated in smaller games, with as few as two players.
+type Matchmaker struct {
+	// Players is the number of players of a game.
+	Players int
+	// Band is the initial difference of rating allowed between players, which widens by Widen every second.
+	Band  float64
+	Widen float64
+	// MaxWait is how long a player waits before being seated in a game of fewer players.
+	MaxWait time.Duration
+
+	hall *Hall
+
+	mu      sync.Mutex
+	queue   []*ticket
+	started bool
+}
+
+// ticket is a player waiting for a quick match.
+type ticket struct {
+	player  *Player
+	rating  float64
+	joined  time.Time
+	matched chan *Room
+}
+
+// Quick is the quick-match queue of the server.
+var Quick = NewMatchmaker(hall)
+
+func NewMatchmaker(h *Hall) *Matchmaker {
+	return &Matchmaker{
+		Players: 4,
+		Band:    100,
+		Widen:   50,
+		MaxWait: 15 * time.Second,
+		hall:    h,
+	}
+}
+
+// Enqueue puts player in the queue, and returns the channel receiving the room the player was entered in once matched.
+// The room is nil if it could not be entered.
+func (m *Matchmaker) Enqueue(player *Player) <-chan *Room {
+	rating := InitialRating
+	if Ratings != nil {
+		r, _ := Ratings.Rating(player.Identity.ID)
+		rating = r.Rating
+	}
+	t := &ticket{player: player, rating: rating, joined: time.Now(), matched: make(chan *Room, 1)}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queue = append(m.queue, t)
+	if !m.started {
+		m.started = true
+		go m.run()
+	}
+	return t.matched
+}
+
+// Cancel removes player from the queue, and reports whether it was still waiting.
+// A player who was already matched must leave the room it receives.
+func (m *Matchmaker) Cancel(player *Player) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, t := range m.queue {
+		if t.player == player {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Matchmaker) run() {
+	tick := time.NewTicker(500 * time.Millisecond)
+	defer tick.Stop()
+	for now := range tick.C {
+		m.mu.Lock()
+		groups := m.match(now)
+		m.mu.Unlock()
+		for _, g := range groups {
+			m.seat(g)
+		}
+	}
+}
+
+// match removes the groups of players to seat together from the queue, the players who waited the longest being matched first.
+func (m *Matchmaker) match(now time.Time) [][]*ticket {
+	var groups [][]*ticket
+	matched := make(map[*ticket]bool)
+	for _, anchor := range m.queue {
+		if matched[anchor] {
+			continue
+		}
+		wait := now.Sub(anchor.joined)
+		band := m.Band + m.Widen*wait.Seconds()
+		candidates := make([]*ticket, 0)
+		for _, t := range m.queue {
+			if t != anchor && !matched[t] && math.Abs(t.rating-anchor.rating) <= band {
+				candidates = append(candidates, t)
+			}
+		}
+		n := m.Players - 1
+		if len(candidates) < n {
+			if wait < m.MaxWait || len(candidates) == 0 {
+				continue
+			}
+			n = len(candidates)
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return math.Abs(candidates[i].rating-anchor.rating) < math.Abs(candidates[j].rating-anchor.rating)
+		})
+		group := append([]*ticket{anchor}, candidates[:n]...)
+		for _, t := range group {
+			matched[t] = true
+		}
+		groups = append(groups, group)
+	}
+
+	queue := make([]*ticket, 0, len(m.queue))
+	for _, t := range m.queue {
+		if !matched[t] {
+			queue = append(queue, t)
+		}
+	}
+	m.queue = queue
+	return groups
+}
+
+// seat creates a room for a group of players and enters them in it.
+func (m *Matchmaker) seat(group []*ticket) {
+	name := "quick-" + NewPlayerID()[:12]
+	cfg := RoomConfig{MaxPlayers: len(group), MinPlayers: 2, LobbyTimeout: 5 * time.Second}
+	glog.Infof("quick match %s for %d players", name, len(group))
+	for _, t := range group {
+		room, err := m.hall.EnterRoom(name, cfg, t.player)
+		if err != nil {
+			glog.Errorf("quick match %s: %v", name, err)
+		}
+		t.matched <- room
+	}
+}
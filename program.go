@@ -0,0 +1,198 @@
+package tron
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/gophergala/tron/protocol"
+)
+
+// ProgramMoveTimeout is the time a program has to move when the snapshot has no deadline, as in simulations.
+const ProgramMoveTimeout = time.Second
+
+// MaxProgramTimeouts is the number of moves in a row a program may miss before it is killed.
+const MaxProgramTimeouts = 3
+
+// RegisterProgram registers the bot program run with argv under name, so that rooms can seat it like a built-in bot.
+func RegisterProgram(name string, argv []string) error {
+	if len(argv) == 0 {
+		return fmt.Errorf("bot %q has no command", name)
+	}
+	if _, ok := Bots[name]; ok {
+		return fmt.Errorf("bot %q registered twice", name)
+	}
+	Bots[name] = func(rnd *rand.Rand) Bot { return &ProgramBot{Argv: argv} }
+	return nil
+}
+
+// RegisterProgramSpec registers a bot program given as name=command, the command being split on spaces.
+func RegisterProgramSpec(spec string) error {
+	i := strings.Index(spec, "=")
+	if i <= 0 {
+		return fmt.Errorf("bot %q is not name=command", spec)
+	}
+	return RegisterProgram(spec[:i], strings.Fields(spec[i+1:]))
+}
+
+// ProgramFlag is a flag.Value registering the bot programs given as name=command flags with RegisterProgramSpec.
+type ProgramFlag struct{}
+
+func (ProgramFlag) String() string { return "" }
+
+func (ProgramFlag) Set(v string) error { return RegisterProgramSpec(v) }
+
+// ProgramBot is a bot run as a subprocess, started on its first move.
+// On every tick the program reads an Observation as a line of JSON on its standard input, and writes a BotMove as a line of JSON on its standard output, whose Direction may be empty to keep going.
+// Moves are due by the deadline of the snapshot: a late move is skipped, and the program is killed after MaxProgramTimeouts late moves in a row, if it exits, or if it writes an invalid move.
+// Once killed, its snake keeps going straight.
+type ProgramBot struct {
+	Argv []string
+
+	cmd      *exec.Cmd
+	stdin    *bufio.Writer
+	lines    chan []byte
+	done     chan struct{}
+	closer   sync.Once
+	timeouts int
+	dead     bool
+}
+
+// start runs the program, and leaves the bot untouched if it cannot be run.
+func (b *ProgramBot) start() error {
+	cmd := exec.Command(b.Argv[0], b.Argv[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	b.cmd = cmd
+	b.stdin = bufio.NewWriter(stdin)
+	b.lines = make(chan []byte)
+	b.done = make(chan struct{})
+	go func() {
+		defer close(b.lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case b.lines <- line:
+			case <-b.done:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// kill stops the program, whose snake keeps going straight from now on.
+func (b *ProgramBot) kill(format string, args ...interface{}) {
+	glog.Warningf("bot %s: "+format+", killing it", append([]interface{}{b.Argv[0]}, args...)...)
+	b.dead = true
+	b.Close()
+}
+
+func (b *ProgramBot) Move(s Snapshot, color Color) Direction {
+	if b.dead {
+		return ""
+	}
+	if b.done == nil {
+		if err := b.start(); err != nil {
+			glog.Errorf("bot %s: %v", b.Argv[0], err)
+			b.dead = true
+			return ""
+		}
+	}
+
+	timeout := ProgramMoveTimeout
+	if !s.Deadline.IsZero() {
+		timeout = s.Deadline.Sub(time.Now())
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	// The program may not read its input, so the observation is written without blocking the game.
+	line, _ := json.Marshal(newObservation(s, color))
+	written := make(chan error, 1)
+	go func() {
+		b.stdin.Write(append(line, '\n'))
+		written <- b.stdin.Flush()
+	}()
+	select {
+	case err := <-written:
+		if err != nil {
+			b.kill("writing observation: %v", err)
+			return ""
+		}
+	case <-timer.C:
+		b.kill("not reading its input")
+		return ""
+	}
+
+	for {
+		select {
+		case line, ok := <-b.lines:
+			if !ok {
+				b.kill("exited")
+				return ""
+			}
+			var m protocol.BotMove
+			if err := json.Unmarshal(line, &m); err != nil {
+				b.kill("invalid move %q: %v", line, err)
+				return ""
+			}
+			if m.Tick < s.Tick {
+				// A late move for a previous tick.
+				continue
+			}
+			if m.Tick > s.Tick {
+				b.kill("move for tick %d during tick %d", m.Tick, s.Tick)
+				return ""
+			}
+			if m.Direction != "" {
+				if err := m.Validate(); err != nil {
+					b.kill("%v", err)
+					return ""
+				}
+			}
+			b.timeouts = 0
+			return Direction(m.Direction)
+		case <-timer.C:
+			b.timeouts += 1
+			if b.timeouts >= MaxProgramTimeouts {
+				b.kill("%d moves timed out in a row", b.timeouts)
+			}
+			return ""
+		}
+	}
+}
+
+// Close kills the program if it is running.
+func (b *ProgramBot) Close() error {
+	if b.done == nil {
+		return nil
+	}
+	b.closer.Do(func() {
+		close(b.done)
+		if b.cmd.Process != nil {
+			b.cmd.Process.Kill()
+		}
+		b.cmd.Wait()
+	})
+	return nil
+}
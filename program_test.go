@@ -0,0 +1,19 @@
+package tron
+
+import (
+	"testing"
+)
+
+func TestProgramStartFailure(t *testing.T) {
+	b := &ProgramBot{Argv: []string{"/nonexistent/bot"}}
+	a := newTestArena(map[Color][]Point{Colors[0]: {{10, 10}, {11, 10}}})
+	s := snapshotOf(a)
+	for i := 0; i < 2; i++ {
+		if d := b.Move(s, Colors[0]); d != "" {
+			t.Errorf("got move %q from a program which did not start, want none", d)
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Errorf("closing: %v", err)
+	}
+}
@@ -0,0 +1,337 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Encodings of the RefreshMap and Delta messages, chosen by a client when it joins.
+// Other messages are always sent as JSON text frames, while binary frames hold a single RefreshMap or Delta.
+const (
+	EncodingJSON   = "json"
+	EncodingBinary = "binary"
+)
+
+// Binary message types, which are the first byte of a binary frame.
+//
+// A RefreshMap frame is followed by uvarint Seq, uvarint Tick, uvarint number of snakes, and for each snake its color ID and points,
+// then by uvarint number of players, and for each player its color ID, ID and Nickname.
+// A Delta frame is followed by uvarint Seq, uvarint Tick, uvarint number of snakes, and for each snake its color ID, uvarint From and points,
+// then by uvarint number of losers, and for each loser its color ID, the color ID of what it collided with, uvarint Tick and a HeadOn byte.
+// Strings are a uvarint length followed by their bytes.
+// Points are a uvarint count followed by the first point as zigzag varints X and Y, and each other point as zigzag varints relative to the point before.
+const (
+	BinaryRefreshMap byte = 1
+	BinaryDelta      byte = 2
+)
+
+// ColorIDWall is the color ID of the wall.
+const ColorIDWall byte = 0xff
+
+// ColorID returns the small integer identifying a color in binary frames, which is its index in Colors.
+func ColorID(c Color) (byte, error) {
+	if c == ColorWall {
+		return ColorIDWall, nil
+	}
+	for i, color := range Colors {
+		if color == c {
+			return byte(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown color %q", c)
+}
+
+func ColorFromID(id byte) (Color, error) {
+	if id == ColorIDWall {
+		return ColorWall, nil
+	}
+	if int(id) >= len(Colors) {
+		return "", fmt.Errorf("unknown color ID %d", id)
+	}
+	return Colors[id], nil
+}
+
+type encoder struct {
+	bytes.Buffer
+	b [binary.MaxVarintLen64]byte
+}
+
+func (e *encoder) uvarint(x int) {
+	n := binary.PutUvarint(e.b[:], uint64(x))
+	e.Write(e.b[:n])
+}
+
+func (e *encoder) varint(x int) {
+	n := binary.PutVarint(e.b[:], int64(x))
+	e.Write(e.b[:n])
+}
+
+func (e *encoder) color(c Color) error {
+	id, err := ColorID(c)
+	if err != nil {
+		return err
+	}
+	e.WriteByte(id)
+	return nil
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(len(s))
+	e.WriteString(s)
+}
+
+func (e *encoder) points(points []Point) {
+	e.uvarint(len(points))
+	var prev Point
+	for _, p := range points {
+		e.varint(p.X - prev.X)
+		e.varint(p.Y - prev.Y)
+		prev = p
+	}
+}
+
+// sortColors sorts colors by ID, so that encoding is deterministic.
+func sortColors(colors []Color) {
+	sort.Slice(colors, func(i, j int) bool {
+		a, _ := ColorID(colors[i])
+		b, _ := ColorID(colors[j])
+		return a < b
+	})
+}
+
+func EncodeRefreshMap(m RefreshMap) ([]byte, error) {
+	e := &encoder{}
+	e.WriteByte(BinaryRefreshMap)
+	e.uvarint(m.Seq)
+	e.uvarint(m.Tick)
+	e.uvarint(len(m.State))
+	colors := make([]Color, 0, len(m.State))
+	for color, _ := range m.State {
+		colors = append(colors, color)
+	}
+	sortColors(colors)
+	for _, color := range colors {
+		if err := e.color(color); err != nil {
+			return nil, err
+		}
+		e.points(m.State[color])
+	}
+	e.uvarint(len(m.Players))
+	colors = colors[:0]
+	for color, _ := range m.Players {
+		colors = append(colors, color)
+	}
+	sortColors(colors)
+	for _, color := range colors {
+		if err := e.color(color); err != nil {
+			return nil, err
+		}
+		e.string(m.Players[color].ID)
+		e.string(m.Players[color].Nickname)
+	}
+	return e.Bytes(), nil
+}
+
+func EncodeDelta(d Delta) ([]byte, error) {
+	e := &encoder{}
+	e.WriteByte(BinaryDelta)
+	e.uvarint(d.Seq)
+	e.uvarint(d.Tick)
+	e.uvarint(len(d.Snakes))
+	colors := make([]Color, 0, len(d.Snakes))
+	for color, _ := range d.Snakes {
+		colors = append(colors, color)
+	}
+	sortColors(colors)
+	for _, color := range colors {
+		if err := e.color(color); err != nil {
+			return nil, err
+		}
+		e.uvarint(d.Snakes[color].From)
+		e.points(d.Snakes[color].Points)
+	}
+	e.uvarint(len(d.Losers))
+	for _, l := range d.Losers {
+		if err := e.color(l.Color); err != nil {
+			return nil, err
+		}
+		if err := e.color(l.CollideWith); err != nil {
+			return nil, err
+		}
+		e.uvarint(l.Tick)
+		if l.HeadOn {
+			e.WriteByte(1)
+		} else {
+			e.WriteByte(0)
+		}
+	}
+	return e.Bytes(), nil
+}
+
+type decoder struct {
+	*bytes.Reader
+}
+
+func (d decoder) uvarint() (int, error) {
+	x, err := binary.ReadUvarint(d)
+	return int(x), err
+}
+
+func (d decoder) varint() (int, error) {
+	x, err := binary.ReadVarint(d)
+	return int(x), err
+}
+
+func (d decoder) color() (Color, error) {
+	id, err := d.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	return ColorFromID(id)
+}
+
+func (d decoder) string() (string, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return "", err
+	}
+	if n > d.Len() {
+		return "", fmt.Errorf("invalid string length %d", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (d decoder) points() ([]Point, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > d.Len() {
+		return nil, fmt.Errorf("invalid number of points %d", n)
+	}
+	points := make([]Point, n)
+	var prev Point
+	for i := range points {
+		dx, err := d.varint()
+		if err != nil {
+			return nil, err
+		}
+		dy, err := d.varint()
+		if err != nil {
+			return nil, err
+		}
+		points[i] = Point{X: prev.X + dx, Y: prev.Y + dy}
+		prev = points[i]
+	}
+	return points, nil
+}
+
+// DecodeFrame decodes a binary frame into a *RefreshMap or a *Delta.
+func DecodeFrame(b []byte) (interface{}, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty frame")
+	}
+	d := decoder{bytes.NewReader(b[1:])}
+	seq, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	tick, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	switch b[0] {
+	case BinaryRefreshMap:
+		m := RefreshMap{Type: TypeRefreshMap, Seq: seq, Tick: tick, State: make(map[Color][]Point)}
+		for i := 0; i < n; i++ {
+			color, err := d.color()
+			if err != nil {
+				return nil, err
+			}
+			if m.State[color], err = d.points(); err != nil {
+				return nil, err
+			}
+		}
+		np, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if np > d.Len() {
+			return nil, fmt.Errorf("invalid number of players %d", np)
+		}
+		if np > 0 {
+			m.Players = make(map[Color]Identity)
+		}
+		for i := 0; i < np; i++ {
+			color, err := d.color()
+			if err != nil {
+				return nil, err
+			}
+			var id Identity
+			if id.ID, err = d.string(); err != nil {
+				return nil, err
+			}
+			if id.Nickname, err = d.string(); err != nil {
+				return nil, err
+			}
+			m.Players[color] = id
+		}
+		return &m, nil
+	case BinaryDelta:
+		delta := Delta{Type: TypeDelta, Seq: seq, Tick: tick, Snakes: make(map[Color]SnakeDelta)}
+		for i := 0; i < n; i++ {
+			color, err := d.color()
+			if err != nil {
+				return nil, err
+			}
+			from, err := d.uvarint()
+			if err != nil {
+				return nil, err
+			}
+			points, err := d.points()
+			if err != nil {
+				return nil, err
+			}
+			delta.Snakes[color] = SnakeDelta{From: from, Points: points}
+		}
+		nl, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if nl > d.Len() {
+			return nil, fmt.Errorf("invalid number of losers %d", nl)
+		}
+		delta.Losers = make([]Loser, nl)
+		for i := range delta.Losers {
+			l := &delta.Losers[i]
+			if l.Color, err = d.color(); err != nil {
+				return nil, err
+			}
+			if l.CollideWith, err = d.color(); err != nil {
+				return nil, err
+			}
+			if l.Tick, err = d.uvarint(); err != nil {
+				return nil, err
+			}
+			headOn, err := d.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			l.HeadOn = headOn != 0
+		}
+		return &delta, nil
+	}
+	return nil, fmt.Errorf("unknown frame type %d", b[0])
+}
@@ -0,0 +1,94 @@
+package protocol
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRefreshMapRoundTrip(t *testing.T) {
+	tests := []RefreshMap{
+		{Type: TypeRefreshMap, Seq: 1, Tick: 0, State: map[Color][]Point{}},
+		{
+			Type: TypeRefreshMap,
+			Seq:  300,
+			Tick: 299,
+			State: map[Color][]Point{
+				"blue":   {{X: 40, Y: 20}, {X: 44, Y: 20}, {X: 44, Y: 4}},
+				"purple": {{X: 996, Y: 596}, {X: 4, Y: 596}},
+			},
+			Players: map[Color]Identity{
+				"blue":   {ID: "0123456789abcdef01234567", Nickname: "zoë"},
+				"purple": {ID: "bot:floodfill", Nickname: "floodfill"},
+			},
+		},
+	}
+	for _, m := range tests {
+		b, err := EncodeRefreshMap(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecodeFrame(b)
+		if err != nil {
+			t.Fatalf("decoding %+v: %v", m, err)
+		}
+		if !reflect.DeepEqual(got, &m) {
+			t.Errorf("got %+v, want %+v", got, &m)
+		}
+	}
+}
+
+func TestDeltaRoundTrip(t *testing.T) {
+	tests := []Delta{
+		{Type: TypeDelta, Seq: 2, Tick: 1, Snakes: map[Color]SnakeDelta{}, Losers: []Loser{}},
+		{
+			Type: TypeDelta,
+			Seq:  1000,
+			Tick: 999,
+			Snakes: map[Color]SnakeDelta{
+				"red":   {From: 3, Points: []Point{{X: 120, Y: 80}, {X: 120, Y: 84}}},
+				"green": {From: 0, Points: []Point{{X: 8, Y: 4}}},
+			},
+			Losers: []Loser{
+				{Color: "orange", CollideWith: ColorWall, Tick: 999},
+				{Color: "black", CollideWith: "red", Tick: 998, HeadOn: true},
+				{Color: "red", CollideWith: "red", Tick: 12},
+			},
+		},
+	}
+	for _, d := range tests {
+		b, err := EncodeDelta(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecodeFrame(b)
+		if err != nil {
+			t.Fatalf("decoding %+v: %v", d, err)
+		}
+		if !reflect.DeepEqual(got, &d) {
+			t.Errorf("got %+v, want %+v", got, &d)
+		}
+	}
+}
+
+func TestDecodeTruncatedFrame(t *testing.T) {
+	b, err := EncodeDelta(Delta{
+		Seq:    5,
+		Tick:   4,
+		Snakes: map[Color]SnakeDelta{"blue": {From: 1, Points: []Point{{X: 4, Y: 8}}}},
+		Losers: []Loser{{Color: "blue", CollideWith: ColorWall, Tick: 4}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(b); i++ {
+		if _, err := DecodeFrame(b[:i]); err == nil {
+			t.Errorf("decoding the first %d of %d bytes did not fail", i, len(b))
+		}
+	}
+}
+
+func TestEncodeUnknownColor(t *testing.T) {
+	if _, err := EncodeRefreshMap(RefreshMap{State: map[Color][]Point{"pink": {{X: 1, Y: 1}}}}); err == nil {
+		t.Errorf("encoding an unknown color did not fail")
+	}
+}
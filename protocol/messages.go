@@ -0,0 +1,287 @@
+package protocol
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Color string
+
+// ColorWall is what a player collided with when it hit the wall.
+const ColorWall Color = "wall"
+
+// Colors are the colors handed out to players, in order.
+// The index of a color is its ID in binary frames.
+var Colors = []Color{"blue", "red", "green", "orange", "black", "purple"}
+
+type Point struct {
+	X int
+	Y int
+}
+
+const (
+	DirectionUp    = "u"
+	DirectionDown  = "d"
+	DirectionLeft  = "l"
+	DirectionRight = "r"
+)
+
+// MaxRoomLen is the maximum length of a room name.
+const MaxRoomLen = 32
+
+// ValidRoom reports whether name is a valid room name, made of 1 to MaxRoomLen letters, digits, dashes and underscores.
+func ValidRoom(name string) bool {
+	if len(name) == 0 || len(name) > MaxRoomLen {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// MaxNicknameLen is the maximum length of a nickname, in characters.
+const MaxNicknameLen = 24
+
+// ValidNickname reports whether name is a valid nickname, made of up to MaxNicknameLen printable characters.
+func ValidNickname(name string) bool {
+	if utf8.RuneCountInString(name) > MaxNicknameLen {
+		return false
+	}
+	for _, c := range name {
+		if !unicode.IsPrint(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Identity is who plays a color.
+type Identity struct {
+	// ID is the persistent ID of a player, or bot:<name> for a bot.
+	ID       string
+	Nickname string
+}
+
+// Join is the first message of a client, which enters a room.
+// The room configuration fields are only used when the room is created by this client, zero values meaning the default.
+type Join struct {
+	// Version is the latest version of the protocol the client supports.
+	Version int
+
+	// Nickname is the name shown to other players, which may be empty.
+	Nickname string
+	// Token is the token of a previous Connected message, to keep the same ID across sessions.
+	// A new ID is given to clients without a valid token.
+	Token string
+
+	Room string
+	// QuickMatch puts the client in the quick-match queue instead of entering Room, which must then be empty.
+	// The client is sent Queued messages until it is matched with other players, then a Matched message with the room they were seated in.
+	// The room configuration fields are ignored.
+	QuickMatch bool
+
+	MaxPlayers int
+	MinPlayers int
+	TickRate   int
+
+	// LobbyTimeout is in seconds.
+	LobbyTimeout *float64
+	// Bots is the name of the bot which fills empty seats once the lobby timeout expires, empty for no bots.
+	// Several bots are separated by commas, and fill the seats in order, the last one filling the remaining seats.
+	Bots string
+
+	// Delta asks for Delta messages between keyframes instead of a RefreshMap on every tick.
+	Delta bool
+	// Encoding is the encoding of RefreshMap and Delta messages, either EncodingJSON, the default, or EncodingBinary.
+	Encoding string
+}
+
+func (m *Join) Validate() error {
+	if m.QuickMatch {
+		if m.Room != "" {
+			return NewError(ErrInvalidRoom, "no room can be given for a quick match")
+		}
+	} else if !ValidRoom(m.Room) {
+		return NewError(ErrInvalidRoom, fmt.Sprintf("invalid room name %q, room names are 1 to %d letters, digits, dashes and underscores", m.Room, MaxRoomLen))
+	}
+	if !ValidNickname(m.Nickname) {
+		return NewError(ErrInvalidNickname, fmt.Sprintf("invalid nickname %q, nicknames are up to %d printable characters", m.Nickname, MaxNicknameLen))
+	}
+	return nil
+}
+
+// Ready asks to play the next game.
+type Ready struct {
+	Color Color
+}
+
+type Move struct {
+	Direction string
+}
+
+func (m *Move) Validate() error {
+	switch m.Direction {
+	case DirectionUp, DirectionDown, DirectionLeft, DirectionRight:
+		return nil
+	}
+	return NewError(ErrInvalidDirection, fmt.Sprintf("invalid direction %q, directions are %q, %q, %q and %q", m.Direction, DirectionUp, DirectionDown, DirectionLeft, DirectionRight))
+}
+
+// BotMove is the move of an external bot for the tick of an Observation.
+// A move for another tick, or received after the deadline, is rejected and the snake keeps going.
+type BotMove struct {
+	Tick      int
+	Direction string
+}
+
+func (m *BotMove) Validate() error {
+	return (&Move{Direction: m.Direction}).Validate()
+}
+
+type Leave struct{}
+
+// Keyframe asks for the next map frame to be a RefreshMap.
+type Keyframe struct{}
+
+// Hello is the first message sent to a client once its version is negotiated, from version 2 onwards.
+type Hello struct {
+	Type    string
+	Version int
+}
+
+func NewHello(version int) Hello {
+	return Hello{Type: TypeHello, Version: version}
+}
+
+// Queued is sent periodically to a client waiting for a quick match.
+type Queued struct {
+	Type string
+	// Wait is the time the client has waited, in seconds.
+	Wait float64
+}
+
+func NewQueued(wait float64) Queued {
+	return Queued{Type: TypeQueued, Wait: wait}
+}
+
+// Matched is sent to a client whose quick match was found, with the room it was seated in.
+type Matched struct {
+	Type string
+	Room string
+}
+
+func NewMatched(room string) Matched {
+	return Matched{Type: TypeMatched, Room: room}
+}
+
+type Connected struct {
+	Type        string
+	Color       Color
+	OtherColors []Color
+
+	// You is the identity of the client, and Token proves its ID when joining again.
+	You   Identity
+	Token string
+}
+
+func NewConnected(color Color, you Identity, token string) Connected {
+	return Connected{Type: TypeConnected, Color: color, You: you, Token: token}
+}
+
+type Countdown struct {
+	Type string
+	Cnt  int
+}
+
+func NewCountdown(cnt int) Countdown {
+	return Countdown{Type: TypeCountdown, Cnt: cnt}
+}
+
+// RefreshMap is a keyframe, which holds the full state of the arena in canvas coordinates.
+type RefreshMap struct {
+	Type  string
+	Seq   int
+	Tick  int
+	State map[Color][]Point
+
+	// Players are the identities of the players by color.
+	Players map[Color]Identity
+}
+
+// SnakeDelta replaces the points of a snake from index From onwards with Points.
+type SnakeDelta struct {
+	From   int
+	Points []Point
+}
+
+// Delta holds the changes since the frame numbered Seq-1, and can only be applied on top of it.
+type Delta struct {
+	Type   string
+	Seq    int
+	Tick   int
+	Snakes map[Color]SnakeDelta
+	Losers []Loser
+}
+
+type Loser struct {
+	Color       Color
+	CollideWith Color
+	Tick        int
+	HeadOn      bool
+}
+
+type Placement struct {
+	Color       Color
+	Place       int
+	Tick        int
+	CollideWith Color
+	HeadOn      bool
+}
+
+type GameResult struct {
+	Winner     Color
+	Draw       bool
+	Placements []Placement
+	Replay     string
+	Players    map[Color]Identity
+}
+
+type GameEnd struct {
+	Type   string
+	Result GameResult
+}
+
+func NewGameEnd(result GameResult) GameEnd {
+	return GameEnd{Type: TypeGameEnd, Result: result}
+}
+
+// Observation is sent to external bots on every tick, with the full grid in cell coordinates, where Y grows upwards.
+// Cells with X or Y at 0 or at Size are walls.
+type Observation struct {
+	Type string
+	Tick int
+	// You is the color of the bot.
+	You Color
+	// Deadline is the time in milliseconds since the Unix epoch by which the BotMove for Tick must be received.
+	// TimeLeft is the time in milliseconds to the deadline when the observation was sent, for bots whose clock is not in sync with the server.
+	Deadline int64
+	TimeLeft int
+	Size     Point
+	// Cells are the cells occupied by each snake, from its tail to its head.
+	Cells  map[Color][]Point
+	Losers []Loser
+}
+
+// Heartbeat is sent periodically to keep the connection alive.
+type Heartbeat struct {
+	Type string
+	HB   int
+}
+
+func NewHeartbeat() Heartbeat {
+	return Heartbeat{Type: TypeHeartbeat}
+}
@@ -0,0 +1,160 @@
+// Package protocol defines the messages exchanged between the game server and its clients over the /Join websocket.
+// Programs playing through the /Bot websocket speak the same protocol, but receive an Observation instead of map frames and reply with a BotMove.
+//
+// Clients send messages wrapped in an envelope holding their Type and Body, while the server sends flat messages carrying their Type next to their fields.
+// The first message of a client must be a Join, which negotiates the version of the protocol.
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Version is the version of the protocol spoken by the server, and MinVersion the oldest version it still supports.
+// Version 1 is the protocol of clients which do not send their version when joining, version 2 adds the Hello message.
+const (
+	Version    = 2
+	MinVersion = 1
+)
+
+// Negotiate returns the version to speak with a client supporting versions up to version, zero meaning a client which predates versioning.
+func Negotiate(version int) (int, error) {
+	if version == 0 {
+		return 1, nil
+	}
+	if version < MinVersion {
+		return 0, NewError(ErrUnsupportedVersion, fmt.Sprintf("version %d is not supported, the oldest supported version is %d", version, MinVersion))
+	}
+	if version > Version {
+		return Version, nil
+	}
+	return version, nil
+}
+
+// Types of the messages sent by clients.
+const (
+	TypeJoin     = "Join"
+	TypeReady    = "Ready"
+	TypeMove     = "Move"
+	TypeLeave    = "Leave"
+	TypeKeyframe = "Keyframe"
+	TypeBotMove  = "BotMove"
+)
+
+// Types of the messages sent by the server.
+const (
+	TypeHello       = "Hello"
+	TypeQueued      = "Queued"
+	TypeMatched     = "Matched"
+	TypeConnected   = "Connected"
+	TypeCountdown   = "Countdown"
+	TypeRefreshMap  = "RefreshMap"
+	TypeDelta       = "Delta"
+	TypeGameEnd     = "GameEnd"
+	TypeError       = "Error"
+	TypeHeartbeat   = "Heartbeat"
+	TypeObservation = "Observation"
+)
+
+// Envelope wraps the body of a message sent by a client.
+type Envelope struct {
+	Type string
+	Body json.RawMessage
+}
+
+var registry = make(map[string]func() interface{})
+
+// Register registers the message type typ, whose messages are decoded into the value returned by newMsg.
+func Register(typ string, newMsg func() interface{}) {
+	if _, ok := registry[typ]; ok {
+		panic(fmt.Sprintf("protocol: message type %q registered twice", typ))
+	}
+	registry[typ] = newMsg
+}
+
+func init() {
+	Register(TypeJoin, func() interface{} { return &Join{} })
+	Register(TypeReady, func() interface{} { return &Ready{} })
+	Register(TypeMove, func() interface{} { return &Move{} })
+	Register(TypeLeave, func() interface{} { return &Leave{} })
+	Register(TypeKeyframe, func() interface{} { return &Keyframe{} })
+	Register(TypeBotMove, func() interface{} { return &BotMove{} })
+
+	Register(TypeHello, func() interface{} { return &Hello{} })
+	Register(TypeQueued, func() interface{} { return &Queued{} })
+	Register(TypeMatched, func() interface{} { return &Matched{} })
+	Register(TypeConnected, func() interface{} { return &Connected{} })
+	Register(TypeCountdown, func() interface{} { return &Countdown{} })
+	Register(TypeRefreshMap, func() interface{} { return &RefreshMap{} })
+	Register(TypeDelta, func() interface{} { return &Delta{} })
+	Register(TypeGameEnd, func() interface{} { return &GameEnd{} })
+	Register(TypeError, func() interface{} { return &Error{} })
+	Register(TypeHeartbeat, func() interface{} { return &Heartbeat{} })
+	Register(TypeObservation, func() interface{} { return &Observation{} })
+}
+
+// Decode decodes a JSON message into a pointer to the type registered for it.
+// The message is either an envelope, whose Body is decoded, or a flat message which is decoded as a whole.
+// The returned error is always an *Error.
+func Decode(b []byte) (interface{}, error) {
+	env := Envelope{}
+	if err := json.Unmarshal(b, &env); err != nil {
+		return nil, NewError(ErrBadMessage, fmt.Sprintf("invalid message: %v", err))
+	}
+	newMsg, ok := registry[env.Type]
+	if !ok {
+		return nil, NewError(ErrUnknownType, fmt.Sprintf("unknown message type %q", env.Type))
+	}
+	v := newMsg()
+	if len(env.Body) > 0 && string(env.Body) != "null" {
+		b = env.Body
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return nil, NewError(ErrBadMessage, fmt.Sprintf("invalid %s message: %v", env.Type, err))
+	}
+	if m, ok := v.(Validator); ok {
+		if err := m.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// Validator is implemented by messages which are checked when decoded.
+type Validator interface {
+	// Validate returns an *Error if the message is invalid.
+	Validate() error
+}
+
+// ErrorCode identifies the cause of an Error.
+type ErrorCode string
+
+const (
+	ErrBadMessage         ErrorCode = "bad_message"
+	ErrUnknownType        ErrorCode = "unknown_type"
+	ErrUnexpectedMessage  ErrorCode = "unexpected_message"
+	ErrUnsupportedVersion ErrorCode = "unsupported_version"
+	ErrInvalidDirection   ErrorCode = "invalid_direction"
+	ErrLateMove           ErrorCode = "late_move"
+	ErrInvalidRoom        ErrorCode = "invalid_room"
+	ErrInvalidNickname    ErrorCode = "invalid_nickname"
+	ErrRoomFull           ErrorCode = "room_full"
+	ErrInvalidConfig      ErrorCode = "invalid_config"
+	ErrNotFound           ErrorCode = "not_found"
+	ErrInternal           ErrorCode = "internal"
+)
+
+// Error is sent to a client whose request failed.
+type Error struct {
+	Type string
+	Code ErrorCode
+	Msg  string
+}
+
+func NewError(code ErrorCode, msg string) *Error {
+	return &Error{Type: TypeError, Code: code, Msg: msg}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Msg)
+}
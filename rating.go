@@ -0,0 +1,155 @@
+package tron
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// InitialRating is the rating of a player who has not played yet.
+const InitialRating = 1500.0
+
+// RatingK is the largest change of rating of a player in a game.
+const RatingK = 32.0
+
+// UpdateRatings returns the ratings after a game of its players, given by their places where 1 is the winner and tied players share a place.
+// Players missing from ratings have the initial rating.
+// This is Elo for 1v1 games, while a game between more players counts as a 1v1 game between each pair of players, scaled so that a game changes a rating by at most RatingK.
+func UpdateRatings(ratings map[string]float64, places map[string]int) map[string]float64 {
+	rating := func(id string) float64 {
+		if r, ok := ratings[id]; ok {
+			return r
+		}
+		return InitialRating
+	}
+	updated := make(map[string]float64, len(places))
+	for id, place := range places {
+		r := rating(id)
+		if len(places) < 2 {
+			updated[id] = r
+			continue
+		}
+		delta := 0.0
+		for other, otherPlace := range places {
+			if other == id {
+				continue
+			}
+			expected := 1 / (1 + math.Pow(10, (rating(other)-r)/400))
+			score := 0.5
+			if place < otherPlace {
+				score = 1
+			} else if place > otherPlace {
+				score = 0
+			}
+			delta += score - expected
+		}
+		updated[id] = r + RatingK*delta/float64(len(places)-1)
+	}
+	return updated
+}
+
+// PlayerRating is the rating of a player identity.
+type PlayerRating struct {
+	ID       string
+	Nickname string
+	Rating   float64
+	Games    int
+	Wins     int
+}
+
+// Leaderboard rates the players of finished matches.
+// Bots are not rated, as each bot is a single identity playing in every room at once.
+type Leaderboard struct {
+	sync.RWMutex
+	m map[string]*PlayerRating
+}
+
+// Ratings rates the players of the games played on the server, nil disables ratings.
+var Ratings = NewLeaderboard()
+
+func NewLeaderboard() *Leaderboard {
+	return &Leaderboard{m: make(map[string]*PlayerRating)}
+}
+
+// Record updates the ratings of the players of a match, leaving out bots.
+// A player seated more than once in a match is rated on its best place only.
+func (l *Leaderboard) Record(m *Match) {
+	places := make(map[string]int)
+	for _, p := range m.Players {
+		if _, ok := places[p.ID]; !ok && p.ID != "" && !IsBotID(p.ID) {
+			places[p.ID] = p.Place
+		}
+	}
+	if len(places) < 2 {
+		return
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	ratings := make(map[string]float64)
+	for id, _ := range places {
+		if r, ok := l.m[id]; ok {
+			ratings[id] = r.Rating
+		}
+	}
+	for id, rating := range UpdateRatings(ratings, places) {
+		r, ok := l.m[id]
+		if !ok {
+			r = &PlayerRating{ID: id}
+			l.m[id] = r
+		}
+		r.Rating = rating
+		r.Games += 1
+		if places[id] == 1 && !m.Draw {
+			r.Wins += 1
+		}
+	}
+	for _, p := range m.Players {
+		if r, ok := l.m[p.ID]; ok && p.Nickname != "" {
+			r.Nickname = p.Nickname
+		}
+	}
+}
+
+// Rebuild rates the players of every match of store, from the oldest to the most recent.
+func (l *Leaderboard) Rebuild(store MatchStore) error {
+	matches, err := store.Matches("", math.MaxInt32)
+	if err != nil {
+		return err
+	}
+	for i := len(matches) - 1; i >= 0; i-- {
+		l.Record(matches[i])
+	}
+	return nil
+}
+
+// Rating returns the rating of the player of ID id.
+func (l *Leaderboard) Rating(id string) (PlayerRating, bool) {
+	l.RLock()
+	defer l.RUnlock()
+	r, ok := l.m[id]
+	if !ok {
+		return PlayerRating{ID: id, Rating: InitialRating}, false
+	}
+	return *r, true
+}
+
+// Top returns up to limit players, the best rated first.
+func (l *Leaderboard) Top(limit int) []PlayerRating {
+	l.RLock()
+	defer l.RUnlock()
+	top := make([]PlayerRating, 0, len(l.m))
+	for _, r := range l.m {
+		top = append(top, *r)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Rating != top[j].Rating {
+			return top[i].Rating > top[j].Rating
+		}
+		return top[i].ID < top[j].ID
+	})
+	if len(top) > limit {
+		top = top[:limit]
+	}
+	return top
+}
@@ -0,0 +1,184 @@
+package tron
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Recording holds everything needed to play a game again: the state of the arena when the game began, and the moves applied on each tick.
+type Recording struct {
+	ID     string
+	Rules  string
+	Seed   int64
+	Ratio  float64
+	Size   Point
+	Snakes map[Color][]Point
+	Acts   []map[Color]Direction
+
+	// Players are the identities of the players of the recorded game.
+	Players map[Color]Identity
+
+	// TickRate is the number of timesteps per second of the recorded game.
+	TickRate int
+
+	// Hash is the hash of the final state of the arena, used to check that a replay matches the original game.
+	Hash uint64
+}
+
+func NewRecording(id string, a *Arena, seed int64, tickRate int) *Recording {
+	snakes := make(map[Color][]Point)
+	for color, s := range a.Snakes {
+		snakes[color] = append([]Point(nil), s...)
+	}
+	r := Recording{
+		ID:     id,
+		Rules:  a.Rules.Name(),
+		Seed:   seed,
+		Ratio:  a.Ratio,
+		Size:   a.Size,
+		Snakes: snakes,
+		Acts:   make([]map[Color]Direction, 0),
+
+		TickRate: tickRate,
+	}
+	return &r
+}
+
+// Arena returns a new arena in the state the recorded game began with.
+func (r *Recording) Arena() (*Arena, error) {
+	rules, ok := Rulesets[r.Rules]
+	if !ok {
+		return nil, fmt.Errorf("unknown ruleset %q", r.Rules)
+	}
+	snakes := make(map[Color][]Point)
+	for color, s := range r.Snakes {
+		snakes[color] = append([]Point(nil), s...)
+	}
+	return NewArena(rules, snakes, r.Ratio, rand.New(rand.NewSource(r.Seed))), nil
+}
+
+// Replay plays the recorded game, calling f with the arena after each tick.
+func (r *Recording) Replay(f func(a *Arena) error) (*Arena, error) {
+	a, err := r.Arena()
+	if err != nil {
+		return nil, err
+	}
+	for _, acts := range r.Acts {
+		a.Update(acts)
+		if err := f(a); err != nil {
+			return a, err
+		}
+	}
+	if h := a.Hash(); h != r.Hash {
+		return a, fmt.Errorf("replay of %s diverged: state %x, recorded %x", r.ID, h, r.Hash)
+	}
+	return a, nil
+}
+
+type ReplayStore interface {
+	Save(r *Recording) error
+	Load(id string) (*Recording, error)
+}
+
+// Replays is where games are recorded, nil disables recording.
+var Replays ReplayStore = NewMemReplayStore(256)
+
+// MemReplayStore keeps the most recent recordings in memory.
+type MemReplayStore struct {
+	sync.RWMutex
+	m     map[string]*Recording
+	order []string
+	max   int
+}
+
+func NewMemReplayStore(max int) *MemReplayStore {
+	s := MemReplayStore{
+		m:     make(map[string]*Recording),
+		order: make([]string, 0, max),
+		max:   max,
+	}
+	return &s
+}
+
+func (s *MemReplayStore) Save(r *Recording) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.m[r.ID]; !ok {
+		s.order = append(s.order, r.ID)
+	}
+	s.m[r.ID] = r
+	for len(s.order) > s.max {
+		delete(s.m, s.order[0])
+		s.order = s.order[1:]
+	}
+	return nil
+}
+
+func (s *MemReplayStore) Load(id string) (*Recording, error) {
+	s.RLock()
+	defer s.RUnlock()
+	r, ok := s.m[id]
+	if !ok {
+		return nil, fmt.Errorf("no such replay")
+	}
+	return r, nil
+}
+
+// DirReplayStore saves each recording as a JSON file in a directory.
+type DirReplayStore struct {
+	Dir string
+}
+
+func NewDirReplayStore(dir string) (*DirReplayStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DirReplayStore{Dir: dir}, nil
+}
+
+func (s *DirReplayStore) path(id string) (string, error) {
+	if id == "" || filepath.Base(id) != id {
+		return "", fmt.Errorf("invalid replay id %q", id)
+	}
+	return filepath.Join(s.Dir, id+".json"), nil
+}
+
+func (s *DirReplayStore) Save(r *Recording) error {
+	p, err := s.path(r.ID)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (s *DirReplayStore) Load(id string) (*Recording, error) {
+	p, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no such replay")
+		}
+		return nil, err
+	}
+	r := &Recording{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
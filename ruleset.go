@@ -0,0 +1,131 @@
+package tron
+
+import (
+	"math/rand"
+)
+
+// Step is the move of a snake for a single timestep.
+type Step struct {
+	Color Color
+	From  Point
+	To    Point
+
+	// Turn is set when the snake changes direction, in which case To starts a new segment.
+	Turn bool
+}
+
+// Ruleset defines the rules of a game.
+type Ruleset interface {
+	Name() string
+
+	// Spawn returns the initial snakes of colors in an arena of the given size.
+	// Any randomness must be drawn from rnd.
+	Spawn(colors []Color, size Point, rnd *rand.Rand) (map[Color][]Point, error)
+
+	// Step computes the next move of the snake of color, given the direction requested by its player.
+	// act is empty if the player did not request a change of direction, and must be ignored if it is not a valid direction.
+	// Any randomness must be drawn from a.Rand.
+	Step(a *Arena, color Color, act Direction) Step
+
+	// Collide returns the players eliminated by steps, which are all applied simultaneously.
+	Collide(a *Arena, steps []Step) []Loser
+
+	// Ended reports whether the game between colors is over.
+	Ended(a *Arena, colors []Color) bool
+}
+
+var Rulesets = map[string]Ruleset{
+	"classic": Classic{},
+}
+
+// Classic is the original ruleset: snakes move one cell per timestep, and are eliminated by walls, trails and head on crashes.
+// The game ends when at most one player is left.
+type Classic struct{}
+
+func (Classic) Name() string {
+	return "classic"
+}
+
+func (Classic) Spawn(colors []Color, size Point, rnd *rand.Rand) (map[Color][]Point, error) {
+	spawns, err := SpawnEllipse(len(colors), size)
+	if err != nil {
+		return nil, err
+	}
+	snakes := make(map[Color][]Point)
+	for i, color := range colors {
+		snakes[color] = spawns[i]
+	}
+	return snakes, nil
+}
+
+func (Classic) Step(a *Arena, color Color, act Direction) Step {
+	snake := a.Snakes[color]
+	prevDirt := computeDirection(snake)
+	dirt := prevDirt
+	if validDirection(act) && !oppositeDirections(act, prevDirt) {
+		dirt = act
+	}
+
+	var p Point
+	last := snake[len(snake)-1]
+	switch dirt {
+	case DirectionUp:
+		p = Point{X: last.X, Y: last.Y + 1}
+	case DirectionDown:
+		p = Point{X: last.X, Y: last.Y - 1}
+	case DirectionLeft:
+		p = Point{X: last.X - 1, Y: last.Y}
+	case DirectionRight:
+		p = Point{X: last.X + 1, Y: last.Y}
+	}
+	return Step{Color: color, From: last, To: p, Turn: dirt != prevDirt}
+}
+
+// Collide resolves collisions against the state of the previous timestep.
+// Snakes whose heads meet in the same cell, or swap places, are all eliminated which results in a draw between them.
+func (Classic) Collide(a *Arena, steps []Step) []Loser {
+	colors := a.Colors()
+	losers := make([]Loser, 0)
+	for _, s := range steps {
+		// Check for collision with the wall
+		p := s.To
+		if a.outside(p) {
+			losers = append(losers, Loser{Color: s.Color, CollideWith: ColorWall, Tick: a.Tick})
+			continue
+		}
+
+		collided := false
+		for _, other := range steps {
+			if other.Color == s.Color {
+				continue
+			}
+			if other.To == p || (other.To == s.From && other.From == p) {
+				losers = append(losers, Loser{Color: s.Color, CollideWith: other.Color, Tick: a.Tick, HeadOn: true})
+				collided = true
+				break
+			}
+		}
+		if collided {
+			continue
+		}
+
+		for _, otherColor := range colors {
+			_, ok := a.Points[otherColor][p]
+			if ok {
+				losers = append(losers, Loser{Color: s.Color, CollideWith: otherColor, Tick: a.Tick})
+				break
+			}
+		}
+	}
+	return losers
+}
+
+func (Classic) Ended(a *Arena, colors []Color) bool {
+	alive := 0
+	for _, color := range colors {
+		if !a.lost(color) {
+			alive += 1
+		}
+	}
+	return alive <= 1
+}
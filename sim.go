@@ -0,0 +1,54 @@
+package tron
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+)
+
+// MaxSimTicks bounds the length of a simulated game, past which it is stopped with the remaining snakes tied.
+const MaxSimTicks = 10000
+
+// Simulate plays a game between bots as fast as they move, with no clock and no server.
+// On every tick, each bot still in the game moves on a snapshot of the arena, and the moves are applied together with Arena.Update, as in a game.
+// The returned recording can be saved as a replay.
+func Simulate(rules Ruleset, bots map[Color]Bot, seed int64) (*Arena, *Recording, error) {
+	colors := make([]Color, 0, len(bots))
+	for color, _ := range bots {
+		colors = append(colors, color)
+	}
+	SortColors(colors)
+
+	var ratio float64 = DefaultSizeRatio
+	snakes, err := rules.Spawn(colors, ArenaSize(ratio), rand.New(rand.NewSource(seed)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("spawning: %v", err)
+	}
+	arena := NewArena(rules, snakes, ratio, rand.New(rand.NewSource(seed)))
+
+	// Bots choose their initial direction, as players do during the countdown.
+	s := snapshotOf(arena)
+	for _, color := range colors {
+		if d := bots[color].Move(s, color); d != "" {
+			arena.ChangeInitDirt(MoveCmd{Color: color, Direction: d})
+		}
+	}
+
+	rec := NewRecording(strconv.FormatInt(seed, 36), arena, seed, DefaultTickRate)
+	for !rules.Ended(arena, colors) && arena.Tick < MaxSimTicks {
+		s := snapshotOf(arena)
+		acts := make(map[Color]Direction)
+		for _, color := range colors {
+			if arena.lost(color) {
+				continue
+			}
+			if d := bots[color].Move(s, color); d != "" && d != computeDirection(s.Snakes[color]) {
+				acts[color] = d
+			}
+		}
+		arena.Update(acts)
+		rec.Acts = append(rec.Acts, acts)
+	}
+	rec.Hash = arena.Hash()
+	return arena, rec, nil
+}
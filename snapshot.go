@@ -0,0 +1,117 @@
+package tron
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gophergala/tron/protocol"
+)
+
+// Snapshot is an immutable copy of the state of an arena at a tick, which is safe to share between goroutines.
+// Snapshots must not be modified once created.
+type Snapshot struct {
+	// Seq numbers the snapshots of a game, so that a delta can only be applied on top of the snapshot before it.
+	Seq    int
+	Tick   int
+	Snakes map[Color][]Point
+	Losers []Loser
+
+	// Players are the identities of the players, sent in keyframes only.
+	Players map[Color]Identity
+
+	Size  Point
+	Ratio float64
+
+	// Deadline is when the game stops taking moves for the next tick.
+	Deadline time.Time
+
+	// enc holds the encodings of the snapshot, shared by its copies, nil for a snapshot which is not sent to clients.
+	enc *encodings
+}
+
+// encodings are the messages of a snapshot, each encoded on first use only, then sent as is to every client asking for it.
+type encodings struct {
+	// prev is the snapshot the delta is from, without its own encodings, nil for the first snapshot of a game.
+	prev *Snapshot
+
+	jsonOnce, binaryOnce, deltaJSONOnce, deltaBinaryOnce sync.Once
+
+	json, deltaJSON     string
+	binary, deltaBinary []byte
+}
+
+// snapshotOf returns a snapshot of the state of a, without its encodings.
+func snapshotOf(a *Arena) Snapshot {
+	snakes := make(map[Color][]Point)
+	for color, s := range a.Snakes {
+		snakes[color] = append([]Point(nil), s...)
+	}
+	return Snapshot{
+		Seq:    1,
+		Tick:   a.Tick,
+		Snakes: snakes,
+		Losers: append([]Loser(nil), a.Losers...),
+		Size:   a.Size,
+		Ratio:  a.Ratio,
+	}
+}
+
+// NewSnapshot returns a snapshot of a played by players, following prev which is nil for the first snapshot of a game.
+func NewSnapshot(a *Arena, players map[Color]Identity, prev *Snapshot) Snapshot {
+	s := snapshotOf(a)
+	s.Players = players
+	s.enc = &encodings{}
+	if prev != nil {
+		s.Seq = prev.Seq + 1
+		p := *prev
+		p.enc = nil
+		s.enc.prev = &p
+	}
+	return s
+}
+
+// JSON returns the RefreshMap message of the snapshot.
+func (s Snapshot) JSON() string {
+	s.enc.jsonOnce.Do(func() {
+		b, _ := json.Marshal(newRefreshMap(s))
+		s.enc.json = string(b)
+	})
+	return s.enc.json
+}
+
+// Binary returns the binary encoding of the RefreshMap message of the snapshot.
+func (s Snapshot) Binary() []byte {
+	s.enc.binaryOnce.Do(func() {
+		s.enc.binary, _ = protocol.EncodeRefreshMap(newRefreshMap(s))
+	})
+	return s.enc.binary
+}
+
+// HasDelta reports whether the snapshot follows another one, which a Delta message can be sent from.
+func (s Snapshot) HasDelta() bool {
+	return s.enc != nil && s.enc.prev != nil
+}
+
+// DeltaJSON returns the Delta message from the previous snapshot, empty for the first snapshot of a game.
+func (s Snapshot) DeltaJSON() string {
+	if !s.HasDelta() {
+		return ""
+	}
+	s.enc.deltaJSONOnce.Do(func() {
+		b, _ := json.Marshal(newDelta(*s.enc.prev, s))
+		s.enc.deltaJSON = string(b)
+	})
+	return s.enc.deltaJSON
+}
+
+// DeltaBinary returns the binary encoding of the Delta message from the previous snapshot, nil for the first snapshot of a game.
+func (s Snapshot) DeltaBinary() []byte {
+	if !s.HasDelta() {
+		return nil
+	}
+	s.enc.deltaBinaryOnce.Do(func() {
+		s.enc.deltaBinary, _ = protocol.EncodeDelta(newDelta(*s.enc.prev, s))
+	})
+	return s.enc.deltaBinary
+}
@@ -0,0 +1,58 @@
+package tron
+
+import (
+	"fmt"
+	"math"
+)
+
+// MinSpawnDistance is the minimum distance in cells between two spawned snakes, and between a snake and the wall.
+const MinSpawnDistance = 10
+
+// SpawnEllipse places n snakes evenly around an ellipse centered in an arena of the given size, each facing the center.
+// The first snake is placed on the left of the arena, and the others follow counter clockwise.
+// An error is returned if the arena is too small for all snakes to be at least MinSpawnDistance apart.
+func SpawnEllipse(n int, size Point) ([][]Point, error) {
+	cx, cy := float64(size.X)/2, float64(size.Y)/2
+	rx, ry := 0.4*float64(size.X), 0.4*float64(size.Y)
+
+	snakes := make([][]Point, n)
+	for i := 0; i < n; i++ {
+		theta := math.Pi + 2*math.Pi*float64(i)/float64(n)
+		first := Point{X: int(math.Round(cx + rx*math.Cos(theta))), Y: int(math.Round(cy + ry*math.Sin(theta)))}
+
+		dx, dy := cx-float64(first.X), cy-float64(first.Y)
+		second := first
+		if math.Abs(dx) >= math.Abs(dy) {
+			if dx >= 0 {
+				second.X += 1
+			} else {
+				second.X -= 1
+			}
+		} else {
+			if dy >= 0 {
+				second.Y += 1
+			} else {
+				second.Y -= 1
+			}
+		}
+		snakes[i] = []Point{first, second}
+	}
+
+	for i, s := range snakes {
+		for _, p := range s {
+			if p.X < MinSpawnDistance || size.X-p.X < MinSpawnDistance || p.Y < MinSpawnDistance || size.Y-p.Y < MinSpawnDistance {
+				return nil, fmt.Errorf("arena of size %v is too small to spawn %d players", size, n)
+			}
+		}
+		for _, other := range snakes[i+1:] {
+			if distance(s[1], other[1]) < MinSpawnDistance {
+				return nil, fmt.Errorf("arena of size %v is too small to spawn %d players", size, n)
+			}
+		}
+	}
+	return snakes, nil
+}
+
+func distance(a, b Point) float64 {
+	return math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y))
+}
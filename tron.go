@@ -1,11 +1,13 @@
 package tron
 
 import (
-	"encoding/json"
 	"fmt"
 	"html/template"
+	"io"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"golang.org/x/net/websocket"
 
 	"github.com/gophergala/tron/aws"
+	"github.com/gophergala/tron/protocol"
 )
 
 var (
@@ -28,115 +31,322 @@ func init() {
 	http.Handle("/chatWS", websocket.Handler(chatWS))
 
 	http.Handle("/Join", websocket.Handler(Join))
+	http.Handle("/Bot", websocket.Handler(BotJoin))
+	http.Handle("/replay/", websocket.Handler(Replay))
+	http.HandleFunc("/api/matches", apiMatches)
+	http.HandleFunc("/api/players/", apiPlayer)
+	http.HandleFunc("/api/leaderboard", apiLeaderboard)
+	http.HandleFunc("/api/rooms", apiRooms)
+	http.HandleFunc("/api/rooms/feed", apiRoomsFeed)
+	http.HandleFunc("/leaderboard", leaderboard)
 	http.HandleFunc("/", root)
 }
 
-type wsData struct {
-	Type string
-	Body json.RawMessage
+func toCanvas(snapshot Snapshot, snake []Point) []protocol.Point {
+	canvas := make([]protocol.Point, len(snake))
+	for i := 0; i < len(canvas); i++ {
+		canvas[i].X = int(float64(snake[i].X) * snapshot.Ratio)
+		canvas[i].Y = int(float64(snapshot.Size.Y-snake[i].Y) * snapshot.Ratio)
+	}
+	return canvas
 }
 
-type WSConnected struct {
-	Type        string
-	Color       Color
-	OtherColors []Color
+func newRefreshMap(snapshot Snapshot) protocol.RefreshMap {
+	canvas := make(map[protocol.Color][]protocol.Point)
+	for color, snake := range snapshot.Snakes {
+		canvas[protocol.Color(color)] = toCanvas(snapshot, snake)
+	}
+	return protocol.RefreshMap{Type: protocol.TypeRefreshMap, Seq: snapshot.Seq, Tick: snapshot.Tick, State: canvas, Players: newIdentities(snapshot.Players)}
+}
+
+func newDelta(prev, snapshot Snapshot) protocol.Delta {
+	snakes := make(map[protocol.Color]protocol.SnakeDelta)
+	for color, snake := range snapshot.Snakes {
+		prevSnake := prev.Snakes[color]
+		from := 0
+		for from < len(snake) && from < len(prevSnake) && snake[from] == prevSnake[from] {
+			from += 1
+		}
+		if from == len(snake) && from == len(prevSnake) {
+			continue
+		}
+		snakes[protocol.Color(color)] = protocol.SnakeDelta{From: from, Points: toCanvas(snapshot, snake[from:])}
+	}
+	losers := make([]protocol.Loser, 0)
+	for _, l := range snapshot.Losers[len(prev.Losers):] {
+		losers = append(losers, protocol.Loser{Color: protocol.Color(l.Color), CollideWith: protocol.Color(l.CollideWith), Tick: l.Tick, HeadOn: l.HeadOn})
+	}
+	return protocol.Delta{
+		Type:   protocol.TypeDelta,
+		Seq:    snapshot.Seq,
+		Tick:   snapshot.Tick,
+		Snakes: snakes,
+		Losers: losers,
+	}
 }
 
-func NewWSConnected(color Color) WSConnected {
-	return WSConnected{Type: "Connected", Color: color}
+func newIdentity(id Identity) protocol.Identity {
+	return protocol.Identity{ID: id.ID, Nickname: id.Nickname}
 }
 
-type WSRefreshMap struct {
-	Type  string
-	State map[Color][]Point
+func newIdentities(players map[Color]Identity) map[protocol.Color]protocol.Identity {
+	identities := make(map[protocol.Color]protocol.Identity)
+	for color, id := range players {
+		identities[protocol.Color(color)] = newIdentity(id)
+	}
+	return identities
+}
+
+func newGameEnd(result GameResult) protocol.GameEnd {
+	r := protocol.GameResult{
+		Winner:     protocol.Color(result.Winner),
+		Draw:       result.Draw,
+		Placements: make([]protocol.Placement, 0, len(result.Placements)),
+		Replay:     result.Replay,
+		Players:    newIdentities(result.Players),
+	}
+	for _, p := range result.Placements {
+		r.Placements = append(r.Placements, protocol.Placement{
+			Color:       protocol.Color(p.Color),
+			Place:       p.Place,
+			Tick:        p.Tick,
+			CollideWith: protocol.Color(p.CollideWith),
+			HeadOn:      p.HeadOn,
+		})
+	}
+	return protocol.NewGameEnd(r)
+}
+
+// KeyframeInterval is the maximum number of deltas sent between two keyframes.
+const KeyframeInterval = 100
+
+// stream decides for a connection whether a snapshot is sent as a delta or as a keyframe.
+// A keyframe is sent periodically, whenever a snapshot was missed, and when the client asks for one.
+type stream struct {
+	delta    bool
+	binary   bool
+	seq      int
+	sinceKey int
+	keyframe chan struct{}
+}
+
+func newStream(delta bool, encoding string) (*stream, error) {
+	s := stream{delta: delta, keyframe: make(chan struct{}, 1)}
+	switch encoding {
+	case "", protocol.EncodingJSON:
+	case protocol.EncodingBinary:
+		s.binary = true
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+	return &s, nil
 }
 
-func NewWSRefreshMap(arena *Arena) WSRefreshMap {
-	canvas := make(map[Color][]Point)
-	for color, snake := range arena.Snakes {
-		canvas[color] = make([]Point, len(snake))
-		for i := 0; i < len(canvas[color]); i++ {
-			canvas[color][i].X = int(float64(snake[i].X) * arena.Ratio)
-			canvas[color][i].Y = int(float64(arena.Size.Y-snake[i].Y) * arena.Ratio)
+func (s *stream) requestKeyframe() {
+	select {
+	case s.keyframe <- struct{}{}:
+	default:
+	}
+}
+
+// frame returns the message to send for snapshot, either a string for a JSON text frame or a []byte for a binary frame.
+func (s *stream) frame(snapshot Snapshot) interface{} {
+	requested := false
+	select {
+	case <-s.keyframe:
+		requested = true
+	default:
+	}
+
+	key := !s.delta || requested || !snapshot.HasDelta() || snapshot.Seq != s.seq+1 || s.sinceKey >= KeyframeInterval
+	s.seq = snapshot.Seq
+	if key {
+		s.sinceKey = 0
+		if s.binary {
+			return snapshot.Binary()
 		}
+		return snapshot.JSON()
 	}
-	return WSRefreshMap{Type: "RefreshMap", State: canvas}
+	s.sinceKey += 1
+	if s.binary {
+		return snapshot.DeltaBinary()
+	}
+	return snapshot.DeltaJSON()
 }
 
-type WSGameEnd struct {
-	Type   string
-	Winner Color
+// sendLoop sends the countdown, map frames and game results of me to ws, until sending fails or stop is closed.
+// frame returns the message to send for a snapshot, as stream.frame does.
+func sendLoop(ws *websocket.Conn, me *Player, frame func(Snapshot) interface{}, stop <-chan struct{}) {
+	tick := time.NewTicker(30 * time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case cnt := <-me.Countdown:
+			if err := websocket.JSON.Send(ws, protocol.NewCountdown(cnt)); err != nil {
+				return
+			}
+		case snapshot := <-me.Arena:
+			if err := websocket.Message.Send(ws, frame(snapshot)); err != nil {
+				return
+			}
+		case ge := <-me.GameEnd:
+			if err := websocket.JSON.Send(ws, newGameEnd(ge)); err != nil {
+				return
+			}
+		case <-stop:
+			return
+		case <-tick.C:
+			if err := websocket.JSON.Send(ws, protocol.NewHeartbeat()); err != nil {
+				return
+			}
+		}
+	}
 }
 
-func NewWSGameEnd(winner Color) WSGameEnd {
-	return WSGameEnd{Type: "GameEnd", Winner: winner}
+// receive reads the next message of a client.
+// An error is returned if the connection failed, while invalid messages are reported to the client and skipped.
+func receive(ws *websocket.Conn) (interface{}, error) {
+	for {
+		var b []byte
+		if err := websocket.Message.Receive(ws, &b); err != nil {
+			return nil, err
+		}
+		msg, err := protocol.Decode(b)
+		if err == nil {
+			return msg, nil
+		}
+		if err := websocket.JSON.Send(ws, err); err != nil {
+			return nil, err
+		}
+	}
 }
 
-type WSError struct {
-	Type string
-	Msg  string
+// receiveJoin reads the Join message of a client and negotiates the version of the protocol, sending Hello to clients which support it.
+func receiveJoin(ws *websocket.Conn) (*protocol.Join, bool) {
+	msg, err := receive(ws)
+	if err != nil {
+		return nil, false
+	}
+	join, ok := msg.(*protocol.Join)
+	if !ok {
+		websocket.JSON.Send(ws, protocol.NewError(protocol.ErrUnexpectedMessage, "the first message must be a Join"))
+		return nil, false
+	}
+	version, err := protocol.Negotiate(join.Version)
+	if err != nil {
+		websocket.JSON.Send(ws, err)
+		return nil, false
+	}
+	if version >= 2 {
+		if err := websocket.JSON.Send(ws, protocol.NewHello(version)); err != nil {
+			return nil, false
+		}
+	}
+	return join, true
 }
 
-func NewWSError(msg string) WSError {
-	return WSError{Type: "Error", Msg: msg}
+// identify returns the identity of a client joining with join, and the token proving its ID.
+// Clients without a valid token get a new ID.
+func identify(join *protocol.Join) (Identity, string) {
+	id, err := ParseIdentityToken(join.Token)
+	if err != nil {
+		if join.Token != "" {
+			glog.V(1).Infof("new player ID instead of token %q: %v", join.Token, err)
+		}
+		id = NewPlayerID()
+	}
+	return Identity{ID: id, Nickname: join.Nickname}, IdentityToken(id)
 }
 
-type WSCountdown struct {
-	Type string
-	Cnt  int
+// roomConfig returns the configuration of the room created by join.
+func roomConfig(join *protocol.Join) RoomConfig {
+	cfg := RoomConfig{
+		MaxPlayers:   join.MaxPlayers,
+		MinPlayers:   join.MinPlayers,
+		LobbyTimeout: DefaultRoomConfig.LobbyTimeout,
+		TickRate:     join.TickRate,
+		Bots:         join.Bots,
+	}
+	if join.LobbyTimeout != nil {
+		cfg.LobbyTimeout = time.Duration(*join.LobbyTimeout * float64(time.Second))
+	}
+	return cfg
 }
 
-func NewWSCountdown(cnt int) WSCountdown {
-	return WSCountdown{Type: "Countdown", Cnt: cnt}
+// quickMatch waits in the quick-match queue until me is entered in a room, sending Queued messages meanwhile.
+// It reports false if the connection failed or the room could not be entered.
+func quickMatch(ws *websocket.Conn, me *Player) (*Room, bool) {
+	matched := Quick.Enqueue(me)
+	start := time.Now()
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case room := <-matched:
+			if room == nil {
+				websocket.JSON.Send(ws, protocol.NewError(protocol.ErrInternal, "could not enter the room of the quick match"))
+				return nil, false
+			}
+			if err := websocket.JSON.Send(ws, protocol.NewMatched(room.Name)); err != nil {
+				hall.LeaveRoom(room.Name, me)
+				return nil, false
+			}
+			return room, true
+		case <-tick.C:
+			if err := websocket.JSON.Send(ws, protocol.NewQueued(time.Since(start).Seconds())); err != nil {
+				if !Quick.Cancel(me) {
+					if room := <-matched; room != nil {
+						hall.LeaveRoom(room.Name, me)
+					}
+				}
+				return nil, false
+			}
+		}
+	}
 }
 
 func Join(ws *websocket.Conn) {
-	data := struct {
-		Body struct {
-			Room string
-		}
-	}{}
-	if err := websocket.JSON.Receive(ws, &data); err != nil {
+	join, ok := receiveJoin(ws)
+	if !ok {
 		return
 	}
+	cfg := roomConfig(join)
+	identity, token := identify(join)
 	me := &Player{
-		Arena:     make(chan *Arena, 32),
-		GameEnd:   make(chan Color, 4),
+		Identity:  identity,
+		Arena:     make(chan Snapshot, 32),
+		GameEnd:   make(chan GameResult, 4),
 		Countdown: make(chan int, 4),
 	}
-	room, err := hall.EnterRoom(data.Body.Room, me)
+	frames, err := newStream(join.Delta, join.Encoding)
 	if err != nil {
-		websocket.JSON.Send(ws, NewWSError(err.Error()))
-
-		// We are just a watcher
-		hall.WatchRoom(data.Body.Room, me)
-		defer hall.UnwatchRoom(data.Body.Room, me)
-		tick := time.NewTicker(30 * time.Second)
-		defer tick.Stop()
-		for {
-			select {
-			case cnt := <-me.Countdown:
-				if err := websocket.JSON.Send(ws, NewWSCountdown(cnt)); err != nil {
-					return
-				}
-			case arena := <-me.Arena:
-				if err := websocket.JSON.Send(ws, NewWSRefreshMap(arena)); err != nil {
-					return
-				}
-			case ge := <-me.GameEnd:
-				if err := websocket.JSON.Send(ws, NewWSGameEnd(ge)); err != nil {
-					return
-				}
-			case <-tick.C:
-				if err := websocket.JSON.Send(ws, struct{ HB int }{HB: 0}); err != nil {
-					return
-				}
-			}
+		websocket.JSON.Send(ws, protocol.NewError(protocol.ErrBadMessage, err.Error()))
+		return
+	}
+	var room *Room
+	if join.QuickMatch {
+		if room, ok = quickMatch(ws, me); !ok {
+			return
+		}
+	} else {
+		room, err = hall.EnterRoom(join.Room, cfg, me)
+		if err == ErrRoomFull {
+			websocket.JSON.Send(ws, protocol.NewError(protocol.ErrRoomFull, err.Error()))
+
+			// We are just a watcher
+			hall.WatchRoom(join.Room, me)
+			defer hall.UnwatchRoom(join.Room, me)
+			sendLoop(ws, me, frames.frame, nil)
+			return
+		}
+		if err != nil {
+			websocket.JSON.Send(ws, protocol.NewError(protocol.ErrInvalidConfig, err.Error()))
+			return
 		}
 	}
-	defer hall.LeaveRoom(data.Body.Room, me)
+	defer hall.LeaveRoom(room.Name, me)
 	game, color := room.Ready(me)
-	if err := websocket.JSON.Send(ws, NewWSConnected(color)); err != nil {
+	if err := websocket.JSON.Send(ws, protocol.NewConnected(protocol.Color(color), newIdentity(identity), token)); err != nil {
 		return
 	}
 
@@ -144,58 +354,98 @@ func Join(ws *websocket.Conn) {
 	go func() {
 		defer close(readStopped)
 		for {
-			data := wsData{}
-			if err := websocket.JSON.Receive(ws, &data); err != nil {
+			msg, err := receive(ws)
+			if err != nil {
 				return
 			}
-			switch data.Type {
-			case "Leave":
+			switch m := msg.(type) {
+			case *protocol.Leave:
 				return
-			case "Ready":
+			case *protocol.Keyframe:
+				frames.requestKeyframe()
+			case *protocol.Ready:
 				game, color = room.Ready(me)
-				if err := websocket.JSON.Send(ws, NewWSConnected(color)); err != nil {
+				if err := websocket.JSON.Send(ws, protocol.NewConnected(protocol.Color(color), newIdentity(identity), token)); err != nil {
 					return
 				}
-			case "Move":
-				body := struct {
-					Direction Direction
-				}{}
-				if err := json.Unmarshal(data.Body, &body); err != nil {
-					glog.Errorf("%v", err)
-					break
-				}
+			case *protocol.Move:
 				select {
-				case game.Move <- MoveCmd{Color: color, Direction: body.Direction}:
+				case game.Move <- MoveCmd{Color: color, Direction: Direction(m.Direction)}:
 				default:
 				}
+			default:
+				if err := websocket.JSON.Send(ws, protocol.NewError(protocol.ErrUnexpectedMessage, fmt.Sprintf("unexpected message %T", m))); err != nil {
+					return
+				}
 			}
 		}
 	}()
 
-	tick := time.NewTicker(30 * time.Second)
-	defer tick.Stop()
-	for {
-		select {
-		case cnt := <-me.Countdown:
-			if err := websocket.JSON.Send(ws, NewWSCountdown(cnt)); err != nil {
-				return
-			}
-		case arena := <-me.Arena:
-			if err := websocket.JSON.Send(ws, NewWSRefreshMap(arena)); err != nil {
-				return
-			}
-		case ge := <-me.GameEnd:
-			if err := websocket.JSON.Send(ws, NewWSGameEnd(ge)); err != nil {
-				return
-			}
-		case <-readStopped:
+	sendLoop(ws, me, frames.frame, readStopped)
+}
+
+// Replay streams the frames of a recorded game, followed by its result.
+// The optional speed query parameter speeds up playback, up to 16 times the normal speed.
+func Replay(ws *websocket.Conn) {
+	id := strings.TrimPrefix(ws.Request().URL.Path, "/replay/")
+	if Replays == nil {
+		websocket.JSON.Send(ws, protocol.NewError(protocol.ErrNotFound, "replays are disabled"))
+		return
+	}
+	rec, err := Replays.Load(id)
+	if err != nil {
+		websocket.JSON.Send(ws, protocol.NewError(protocol.ErrNotFound, err.Error()))
+		return
+	}
+
+	speed := 1.0
+	if s := ws.Request().URL.Query().Get("speed"); s != "" {
+		speed, err = strconv.ParseFloat(s, 64)
+		if err != nil || speed < 1 || speed > 16 {
+			websocket.JSON.Send(ws, protocol.NewError(protocol.ErrBadMessage, "invalid speed"))
 			return
-		case <-tick.C:
-			if err := websocket.JSON.Send(ws, struct{ HB int }{HB: 0}); err != nil {
-				return
-			}
 		}
 	}
+
+	arena, err := rec.Arena()
+	if err != nil {
+		websocket.JSON.Send(ws, protocol.NewError(protocol.ErrInternal, err.Error()))
+		return
+	}
+	snapshot := NewSnapshot(arena, rec.Players, nil)
+	if err := websocket.Message.Send(ws, snapshot.JSON()); err != nil {
+		return
+	}
+
+	rate := rec.TickRate
+	if rate == 0 {
+		rate = DefaultTickRate
+	}
+	tick := time.NewTicker(time.Duration(float64(TickPeriod(rate)) / speed))
+	defer tick.Stop()
+	sendErr := false
+	arena, err = rec.Replay(func(a *Arena) error {
+		<-tick.C
+		snapshot = NewSnapshot(a, rec.Players, &snapshot)
+		if err := websocket.Message.Send(ws, snapshot.JSON()); err != nil {
+			sendErr = true
+			return err
+		}
+		return nil
+	})
+	if sendErr {
+		return
+	}
+	if err != nil {
+		glog.Errorf("%v", err)
+		websocket.JSON.Send(ws, protocol.NewError(protocol.ErrInternal, err.Error()))
+		return
+	}
+
+	result := NewGameResult(arena.Colors(), arena)
+	result.Replay = rec.ID
+	result.Players = rec.Players
+	websocket.JSON.Send(ws, newGameEnd(result))
 }
 
 var chats = struct {
@@ -250,7 +500,21 @@ L:
 	}
 }
 
-var chatTmpl = template.Must(template.ParseFiles(fmt.Sprintf("%s/tmpl/chat.html", assetsPath)))
+// lazyTemplate is a template parsed on first use, so that programs which do not serve pages, such as tronsim, do not need the assets.
+type lazyTemplate struct {
+	once sync.Once
+	name string
+	t    *template.Template
+}
+
+func (l *lazyTemplate) Execute(w io.Writer, data interface{}) error {
+	l.once.Do(func() {
+		l.t = template.Must(template.ParseFiles(fmt.Sprintf("%s/tmpl/%s", assetsPath, l.name)))
+	})
+	return l.t.Execute(w, data)
+}
+
+var chatTmpl = &lazyTemplate{name: "chat.html"}
 
 func chat(w http.ResponseWriter, r *http.Request) {
 	page := struct {
@@ -261,7 +525,7 @@ func chat(w http.ResponseWriter, r *http.Request) {
 	chatTmpl.Execute(w, page)
 }
 
-var rootTmpl = template.Must(template.ParseFiles(fmt.Sprintf("%s/tmpl/index.html", assetsPath)))
+var rootTmpl = &lazyTemplate{name: "index.html"}
 
 func root(w http.ResponseWriter, r *http.Request) {
 	page := struct {
@@ -272,6 +536,27 @@ func root(w http.ResponseWriter, r *http.Request) {
 	rootTmpl.Execute(w, page)
 }
 
+var leaderboardTmpl = &lazyTemplate{name: "leaderboard.html"}
+
+func leaderboard(w http.ResponseWriter, r *http.Request) {
+	type row struct {
+		Rank int
+		PlayerRating
+	}
+	page := struct {
+		IP      string
+		Players []row
+	}{
+		IP: PublicIPv4(),
+	}
+	if Ratings != nil {
+		for i, r := range Ratings.Top(DefaultAPILimit) {
+			page.Players = append(page.Players, row{Rank: i + 1, PlayerRating: r})
+		}
+	}
+	leaderboardTmpl.Execute(w, page)
+}
+
 func PublicIPv4() string {
 	if AppID == "" {
 		return "web1.tunnlr.com:11630"